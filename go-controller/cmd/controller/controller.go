@@ -19,11 +19,14 @@ import (
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/minesweeper"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/rcmode/duckshoot"
 
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/dashboard"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/hardware"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/joystick"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/pausemode"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/rcmode"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/screen"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/simbot"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/telemetry"
 )
 
 type Mode interface {
@@ -47,15 +50,35 @@ func main() {
 	// Hook Ctrl-C etc.
 	registerSignalHandlers(cancel)
 
+	// Start the flight recorder before anything else so that we capture the whole run.
+	if err := telemetry.Start(ctx); err != nil {
+		fmt.Println("Failed to start telemetry recorder; continuing without it:", err)
+	}
+	defer telemetry.Stop()
+
 	// Initialise the hardware.
-	hw := hardware.New()
+	hw := newHardware()
 	defer func() {
 		fmt.Println("Zeroing motors for shut down")
 		hw.Shutdown()
+		if faults := hw.PicoFaults(); len(faults) > 0 {
+			fmt.Println("Pico faults this run:")
+			for _, f := range faults {
+				fmt.Println("  ", f)
+			}
+		}
 		time.Sleep(100 * time.Millisecond)
 	}()
 	hw.Start(ctx)
 
+	// Serve the live dashboard for the pit crew.
+	dashboardAddr := os.Getenv("DASHBOARD_ADDR")
+	if dashboardAddr == "" {
+		dashboardAddr = dashboard.DefaultAddr
+	}
+	dashboardExtras := dashboard.NewExtras()
+	go dashboard.Serve(ctx, dashboardAddr, hw, dashboardExtras)
+
 	// Wait for the joystick and kick off a background thread to read from it.
 	joystickEvents := initJoystick(cancel, ctx)
 
@@ -63,17 +86,18 @@ func main() {
 
 	allModes := []Mode{
 		rcmode.New("GUN MODE", "/sounds/duckshootmode.wav", hw, duckshoot.NewServoController()),
-		challengemode.New(hw, calxheading.New()),
-		challengemode.New(hw, escaperoute.New()),
-		challengemode.New(hw, lavapalava.New()),
-		challengemode.New(hw, minesweeper.New()),
-		challengemode.New(hw, ecodisaster.New()),
-		challengemode.New(hw, zombie.New(hw)),
+		challengemode.New(hw, dashboardExtras, calxheading.New()),
+		challengemode.New(hw, dashboardExtras, escaperoute.New()),
+		challengemode.New(hw, dashboardExtras, lavapalava.New()),
+		challengemode.New(hw, dashboardExtras, minesweeper.New()),
+		challengemode.New(hw, dashboardExtras, ecodisaster.New()),
+		challengemode.New(hw, dashboardExtras, zombie.New(hw)),
 		pausemode.New(hw),
 	}
 	var activeMode Mode = allModes[0]
 	fmt.Printf("----- %s -----\n", activeMode.Name())
 	screen.SetMode(activeMode.Name())
+	telemetry.RecordMode(activeMode.Name())
 	activeMode.Start(ctx)
 	activeModeIdx := 0
 
@@ -88,6 +112,7 @@ func main() {
 		activeMode = allModes[activeModeIdx]
 		fmt.Printf("----- %s -----\n", activeMode.Name())
 		screen.SetMode(activeMode.Name())
+		telemetry.RecordMode(activeMode.Name())
 
 		hw.PlaySound(activeMode.StartupSound())
 
@@ -127,6 +152,13 @@ func main() {
 				fmt.Printf("Share pressed: switching modes <<\n")
 				switchMode(-1)
 				continue
+			} else if event.Type == joystick.EventTypeButton &&
+				event.Number == joystick.ButtonPS &&
+				event.Value == 1 {
+				// Lets the motors run again after repeated motor faults.
+				fmt.Printf("PS pressed: clearing any Pico lockout\n")
+				hw.ClearPicoLockout()
+				continue
 			}
 			// Pass other joystick events through if this mode requires them.
 			if ju, ok := activeMode.(JoystickUser); ok {
@@ -151,6 +183,21 @@ func main() {
 	}
 }
 
+type robotHardware interface {
+	hardware.Interface
+	Shutdown()
+}
+
+// newHardware returns the real hardware, or, if TIGERBOT_SIM is set, a simulated robot so that the
+// modes can be exercised off the Pi.
+func newHardware() robotHardware {
+	if os.Getenv("TIGERBOT_SIM") != "" {
+		fmt.Println("Using simulated hardware")
+		return hardware.NewSim(simbot.DefaultArena())
+	}
+	return hardware.New()
+}
+
 func initJoystick(cancel context.CancelFunc, ctx context.Context) chan *joystick.Event {
 	joystickEvents := make(chan *joystick.Event, 1)
 	firstLog := true
@@ -207,6 +254,7 @@ func loopReadingJoystickEvents(ctx context.Context, j *joystick.Joystick, events
 			return err
 		}
 		fmt.Printf("Joy: %s\n", event)
+		telemetry.RecordJoystick(event.Time, uint8(event.Type), event.Number, event.Value)
 		events <- event
 	}
 	return ctx.Err()
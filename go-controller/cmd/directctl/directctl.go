@@ -10,6 +10,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/kinematics"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
 
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/joystick"
@@ -59,25 +60,14 @@ func main() {
 			}
 		case <-ticker.C:
 
-			// Map the values to speeds for each motor.  Motor rotation direction:
-			// positive = anti-clockwise.
-			frontLeft := throttle - rotation - translation
-			backLeft := throttle - rotation + translation
-
-			frontRight := -throttle - rotation - translation
-			backRight := -throttle - rotation + translation
-
-			m := max(frontLeft, frontRight, backLeft, backRight)
-			scale := 1.0
-			if m > 1 {
-				scale = 1.0 / m
-			}
+			// Map the values to speeds for each motor, keeping them all within full range.
+			wheels, _ := kinematics.Desaturate(kinematics.Mix(throttle, translation, rotation), 1)
 
 			const motorFullRange = 0x5fff
-			fl := scaleMotorOutput(frontLeft*scale, motorFullRange)
-			fr := scaleMotorOutput(frontRight*scale, motorFullRange)
-			bl := scaleMotorOutput(backLeft*scale, motorFullRange)
-			br := scaleMotorOutput(backRight*scale, motorFullRange)
+			fl := scaleMotorOutput(wheels[picobldc.FrontLeft], motorFullRange)
+			fr := scaleMotorOutput(wheels[picobldc.FrontRight], motorFullRange)
+			bl := scaleMotorOutput(wheels[picobldc.BackLeft], motorFullRange)
+			br := scaleMotorOutput(wheels[picobldc.BackRight], motorFullRange)
 
 			pico.SetMotorSpeeds(fl, fr, bl, br)
 		case <-metricsTicker.C:
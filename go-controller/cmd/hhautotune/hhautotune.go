@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/autotune"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/hardware"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/kinematics"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/simbot"
+)
+
+// sampleInterval matches the IMU's report rate.
+const sampleInterval = 10 * time.Millisecond
+
+type robotHardware interface {
+	hardware.Interface
+	Shutdown()
+}
+
+type gainSetter interface {
+	SetGains(g headingholder.Gains)
+}
+
+// hhautotune runs a relay-feedback experiment to model how the bot turns, proposes heading PID gains
+// from the model and then compares step responses with the current and proposed gains.  It only
+// prints the gains; copy them into /cfg/heading-gains.yaml to use them.
+func main() {
+	relayRate := flag.Float64("relay-rate", 300, "wheel speed (mm/s) to turn at during the relay experiment")
+	relayTime := flag.Duration("relay-time", 8*time.Second, "how long to run the relay experiment")
+	relaySettle := flag.Duration("relay-settle", 2*time.Second, "how long to let the relay oscillation build up before measuring it")
+	hysteresis := flag.Float64("relay-hysteresis", 2, "how far (degrees) past the centre the relay switches direction")
+	step := flag.Float64("step", 90, "size of the step response turns, in degrees")
+	stepTime := flag.Duration("step-time", 3*time.Second, "how long to record each step response")
+	out := flag.String("out", "hhautotune.png", "file to write the plot of the responses to")
+	flag.Parse()
+
+	fmt.Println("---- HH autotune ----")
+	fmt.Println("GOMAXPROCS", runtime.GOMAXPROCS(0))
+
+	// Our global context, we cancel it to trigger shutdown.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Initialise the hardware.
+	var hw robotHardware
+	if os.Getenv("TIGERBOT_SIM") != "" {
+		fmt.Println("Using simulated hardware")
+		hw = hardware.NewSim(simbot.DefaultArena())
+	} else {
+		hw = hardware.New()
+	}
+	defer func() {
+		fmt.Println("Zeroing motors for shut down")
+		hw.Shutdown()
+		time.Sleep(100 * time.Millisecond)
+	}()
+	hw.Start(ctx)
+
+	hh := hw.StartHeadingHoldMode()
+	gs, ok := hh.(gainSetter)
+	if !ok {
+		fmt.Println("Heading holder doesn't support setting gains")
+		return
+	}
+	hw.UseMotionLimits(hardware.LimitsChallenge)
+	careful := hw.MotionLimits()
+
+	// Relay experiment: we move the target well to one side or the other of the centre as the relay
+	// switches.  With a huge proportional gain and nothing else, the heading holder then turns at full
+	// rate towards the target and the rotation limit sets the rate.
+	fmt.Printf("Relay experiment at %.0fmm/s for %v...\n", *relayRate, *relayTime)
+	relayLimits := careful
+	relayLimits.MaxRotationMMPerS = *relayRate
+	hw.SetMotionLimits(relayLimits)
+	gs.SetGains(headingholder.Gains{Kp: 1000})
+	const (
+		relayCentre = 10
+		// relayOffset is far enough from the centre that the heading holder always turns flat out.
+		relayOffset = 45
+	)
+	start := hw.CurrentHeading()
+	relay := autotune.Relay{CentreDegrees: relayCentre, HysteresisDegrees: *hysteresis}
+	relaySamples := record(hw, *relayTime, func(s autotune.Sample) {
+		if relay.Update(s.HeadingDegrees) {
+			hh.SetHeading(start.AddFloat(relayCentre + relayOffset).Float())
+		} else {
+			hh.SetHeading(start.AddFloat(relayCentre - relayOffset).Float())
+		}
+	})
+
+	gs.SetGains(hw.HeadingGains().Absolute)
+	hw.SetMotionLimits(careful)
+	hh.SetHeading(start.Float())
+	time.Sleep(*stepTime)
+
+	panels := []autotune.Panel{{
+		Title:         fmt.Sprintf("Relay at %.0fmm/s", *relayRate),
+		Traces:        []autotune.Trace{{Name: "heading", Samples: relaySamples}},
+		TargetDegrees: []float64{relayCentre - *hysteresis, relayCentre, relayCentre + *hysteresis},
+	}}
+	defer func() {
+		if err := autotune.Plot(*out, panels); err != nil {
+			fmt.Println("Failed to write plot:", err)
+			return
+		}
+		fmt.Println("Wrote plot to", *out)
+	}()
+
+	result, err := autotune.AnalyseRelay(relaySamples, relayCentre, *relaySettle)
+	if err != nil {
+		fmt.Println("Failed to analyse relay experiment:", err)
+		return
+	}
+	relayDegreesPerS := *relayRate * 360 / kinematics.Default().TurningCircleMM
+	plant := autotune.IdentifyPlant(result, relayDegreesPerS, *hysteresis)
+	fmt.Printf("Relay: %d cycles, period %v, amplitude %.2f degrees\n", result.Cycles, result.Period, result.AmplitudeDegrees)
+	fmt.Printf("Plant: gain %.3f, dead time %v, ultimate gain %.2f, ultimate period %v\n",
+		plant.Gain, plant.DeadTime, plant.UltimateGain(), plant.UltimatePeriod())
+
+	current := hw.HeadingGains()
+	var proposed hardware.HeadingGainsConfig
+	proposed.Absolute, proposed.Relative = autotune.ProposeGains(plant)
+
+	// Step responses.  The yaw rate controller holds its heading with the same PID so it can be
+	// tried out in heading hold mode too.
+	stepPanel := autotune.Panel{
+		Title:         fmt.Sprintf("%.0f degree steps", *step),
+		TargetDegrees: []float64{0, *step},
+	}
+	for _, c := range []struct {
+		name  string
+		gains headingholder.Gains
+	}{
+		{"current absolute", current.Absolute},
+		{"proposed absolute", proposed.Absolute},
+		{"proposed relative", proposed.Relative},
+	} {
+		gs.SetGains(c.gains)
+		start := hw.CurrentHeading()
+		hh.SetHeading(start.AddFloat(*step).Float())
+		samples := record(hw, *stepTime, nil)
+		r := autotune.AnalyseStep(samples, 0, *step)
+		fmt.Printf("Step with %s gains (%v): rise %v, overshoot %.1f degrees, settled %v after %v\n",
+			c.name, c.gains, r.RiseTime, r.OvershootDegrees, r.Settled, r.SettlingTime)
+		stepPanel.Traces = append(stepPanel.Traces, autotune.Trace{Name: c.name, Samples: samples})
+
+		// Go back for the next one.
+		hh.SetHeading(start.Float())
+		time.Sleep(*stepTime)
+	}
+	panels = append(panels, stepPanel)
+
+	cfgBytes, err := yaml.Marshal(&proposed)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("Proposed /cfg/heading-gains.yaml:\n%s", cfgBytes)
+}
+
+// record records the heading until d has passed, calling onSample, if set, with each sample.
+func record(hw hardware.Interface, d time.Duration, onSample func(s autotune.Sample)) []autotune.Sample {
+	var rec autotune.Recorder
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+	deadline := time.Now().Add(d)
+	for now := time.Now(); now.Before(deadline); now = <-ticker.C {
+		rec.Add(now, hw.CurrentHeading())
+		if onSample != nil {
+			onSample(rec.Samples[len(rec.Samples)-1])
+		}
+	}
+	return rec.Samples
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/replay"
+)
+
+// hhreplay replays the heading holder runs from a telemetry log through the current heading holder
+// code and reports where the motor commands differ from the recording.  Exits non-zero if any command
+// differs by more than the tolerance, so it can be used as a regression check after retuning.
+func main() {
+	tolerance := flag.Int("tolerance", 1, "allowed difference in motor speed (Pico-BLDC units) before a command counts as a mismatch")
+	segment := flag.Int("segment", -1, "only replay the segment with this index")
+	verbose := flag.Bool("v", false, "print every mismatching command rather than just the first few")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Println("Usage: hhreplay [flags] <file.tlm>")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+
+	entries, err := replay.Load(flag.Arg(0))
+	if err != nil {
+		fmt.Println("Failed to load log:", err)
+		os.Exit(1)
+	}
+
+	segments := replay.Segments(entries)
+	if len(segments) == 0 {
+		fmt.Println("No heading holder runs in log.")
+		os.Exit(1)
+	}
+
+	failed := false
+	for i, seg := range segments {
+		if *segment >= 0 && i != *segment {
+			continue
+		}
+		result := replay.Run(seg)
+		mismatches := result.Mismatches(*tolerance)
+		fmt.Printf("Segment %d: %v: %d commands, %d mismatches, max diff %d\n",
+			i, seg, len(result.Commands), len(mismatches), result.MaxDiff())
+		for j, c := range mismatches {
+			if j >= 10 && !*verbose {
+				fmt.Printf("  ... and %d more\n", len(mismatches)-j)
+				break
+			}
+			fmt.Printf("  %8.3fs recorded %+v produced %+v\n",
+				c.Time.Sub(seg.Start.Time).Seconds(), c.Recorded, c.Produced)
+		}
+		if len(mismatches) > 0 {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
+)
+
+// picocalibrate runs the Pico-BLDC's motor calibration and saves the result for the driver to load at
+// start up.  Run it with the robot on blocks and the motors powered, whenever a motor is swapped.
+func main() {
+	output := flag.String("o", picobldc.CalibrationFile, "file to write the calibration to")
+	dryRun := flag.Bool("n", false, "print the calibration but don't save it")
+	flag.Parse()
+
+	pico, err := picobldc.New()
+	if err != nil {
+		fmt.Println("Failed to open Pico-BLDC:", err)
+		os.Exit(1)
+	}
+	defer pico.Close()
+
+	if old, err := picobldc.LoadCalibration(*output); err == nil {
+		fmt.Printf("Current calibration: %04x\n", old)
+	}
+
+	fmt.Println("Calibrating...")
+	if err := pico.Calibrate(); err != nil {
+		fmt.Println("Calibration failed:", err)
+		os.Exit(1)
+	}
+	words, err := pico.CalibrationWords()
+	if err != nil {
+		fmt.Println("Failed to read back calibration:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("New calibration:     %04x\n", words)
+	for m, w := range words {
+		if w == 0 {
+			fmt.Printf("Motor %d didn't calibrate; is it connected?\n", m)
+			os.Exit(1)
+		}
+	}
+
+	if *dryRun {
+		return
+	}
+	if err := picobldc.SaveCalibration(*output, words); err != nil {
+		fmt.Println("Failed to save calibration:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Saved calibration to", *output)
+}
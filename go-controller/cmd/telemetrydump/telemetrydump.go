@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/telemetry"
+)
+
+// telemetrydump prints a flight recorder log as text, one record per line, with times relative to
+// the start of the run.
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Println("Usage: telemetrydump <file.tlm>")
+		os.Exit(1)
+	}
+
+	f, err := os.Open(os.Args[1])
+	if err != nil {
+		fmt.Println("Failed to open log:", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	r, err := telemetry.NewReader(f)
+	if err != nil {
+		fmt.Println("Failed to read log:", err)
+		os.Exit(1)
+	}
+	fmt.Println("Run started", r.StartTime)
+
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			fmt.Println("Failed to read log:", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%10.4f %T %+v\n", e.Time.Sub(r.StartTime).Seconds(), e.Record, e.Record)
+	}
+}
@@ -0,0 +1,203 @@
+// Package autotune works out heading PID gains from the bot's measured response.
+//
+// The bot's rotation is modelled as an integrator with a dead time: once the heading holder asks for
+// a turn rate, the heading changes at Gain times that rate, but only after DeadTime, which covers the
+// IMU and control loop delays and the motors' lag.  A relay-feedback experiment (turn one way at a
+// fixed rate until past the target, then the other way) makes the bot oscillate about the target and
+// the period and size of the oscillation give us the model.  The relay has some hysteresis so that
+// IMU noise near the target doesn't make it chatter.
+package autotune
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder/angle"
+)
+
+// Sample is a heading measurement relative to the start of an experiment.
+type Sample struct {
+	Time time.Duration
+	// HeadingDegrees is unwrapped so that it doesn't jump at ±180.
+	HeadingDegrees float64
+}
+
+// Recorder turns IMU headings into Samples.
+type Recorder struct {
+	Samples []Sample
+
+	start   time.Time
+	last    angle.PlusMinus180
+	heading float64
+}
+
+func (r *Recorder) Add(t time.Time, heading angle.PlusMinus180) {
+	if r.start.IsZero() {
+		r.start = t
+	} else {
+		r.heading += heading.Sub(r.last).Float()
+	}
+	r.last = heading
+	r.Samples = append(r.Samples, Sample{Time: t.Sub(r.start), HeadingDegrees: r.heading})
+}
+
+// Relay decides which way to turn in a relay-feedback experiment.
+type Relay struct {
+	CentreDegrees     float64
+	HysteresisDegrees float64
+
+	positive bool
+}
+
+// Update returns true if the bot should turn in the positive (anti-clockwise) direction.  The relay
+// only switches once the heading is HysteresisDegrees past the centre.
+func (r *Relay) Update(headingDegrees float64) bool {
+	if headingDegrees < r.CentreDegrees-r.HysteresisDegrees {
+		r.positive = true
+	} else if headingDegrees > r.CentreDegrees+r.HysteresisDegrees {
+		r.positive = false
+	}
+	return r.positive
+}
+
+// RelayResult describes the oscillation seen in a relay-feedback experiment.
+type RelayResult struct {
+	Cycles int
+	Period time.Duration
+	// AmplitudeDegrees is half the peak-to-peak swing, averaged over the cycles.
+	AmplitudeDegrees float64
+}
+
+var ErrTooFewCycles = errors.New("too few oscillations to analyse")
+
+// AnalyseRelay measures the oscillation about centreDegrees, ignoring the samples before settle
+// while the oscillation builds up.
+func AnalyseRelay(samples []Sample, centreDegrees float64, settle time.Duration) (RelayResult, error) {
+	// Find the times when the heading crossed the centre going up, interpolating between samples.
+	var crossings []time.Duration
+	var crossingIdxs []int
+	for i := 1; i < len(samples); i++ {
+		prev, cur := samples[i-1], samples[i]
+		if prev.Time < settle || prev.HeadingDegrees >= centreDegrees || cur.HeadingDegrees < centreDegrees {
+			continue
+		}
+		frac := (centreDegrees - prev.HeadingDegrees) / (cur.HeadingDegrees - prev.HeadingDegrees)
+		crossings = append(crossings, prev.Time+time.Duration(frac*float64(cur.Time-prev.Time)))
+		crossingIdxs = append(crossingIdxs, i)
+	}
+	if len(crossings) < 3 {
+		return RelayResult{}, ErrTooFewCycles
+	}
+
+	var result RelayResult
+	result.Cycles = len(crossings) - 1
+	result.Period = (crossings[len(crossings)-1] - crossings[0]) / time.Duration(result.Cycles)
+	for c := 0; c < result.Cycles; c++ {
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, s := range samples[crossingIdxs[c]:crossingIdxs[c+1]] {
+			lo = min(lo, s.HeadingDegrees)
+			hi = max(hi, s.HeadingDegrees)
+		}
+		result.AmplitudeDegrees += (hi - lo) / 2
+	}
+	result.AmplitudeDegrees /= float64(result.Cycles)
+	return result, nil
+}
+
+// Plant is the model of the bot's rotation, see the package doc.
+type Plant struct {
+	Gain     float64
+	DeadTime time.Duration
+}
+
+// IdentifyPlant works out the model from a relay experiment that turned at relayDegreesPerS.
+func IdentifyPlant(r RelayResult, relayDegreesPerS, hysteresisDegrees float64) Plant {
+	// The relay switches as the heading passes the hysteresis band but the bot keeps turning for
+	// DeadTime, at Gain * relayDegreesPerS, so:
+	//
+	//	amplitude = hysteresis + Gain * relay * DeadTime
+	//	period/4  = DeadTime + hysteresis / (Gain * relay)
+	rate := 4 * r.AmplitudeDegrees / r.Period.Seconds()
+	return Plant{
+		Gain:     rate / relayDegreesPerS,
+		DeadTime: time.Duration(float64(r.Period) * (r.AmplitudeDegrees - hysteresisDegrees) / (4 * r.AmplitudeDegrees)),
+	}
+}
+
+// UltimateGain and UltimatePeriod are the proportional gain that would just keep the bot
+// oscillating, and the period of that oscillation.  The usual tuning rules are based on them.
+func (p Plant) UltimateGain() float64 {
+	return math.Pi / (2 * p.Gain * p.DeadTime.Seconds())
+}
+
+func (p Plant) UltimatePeriod() time.Duration {
+	return 4 * p.DeadTime
+}
+
+// ProposeGains applies the Ziegler-Nichols rules to the plant.  The absolute heading holder gets the
+// "no overshoot" variant since overshooting a turn throws off the moves that follow.  The yaw rate
+// controller is following a human, who'd rather it was snappy, so it gets the classic rule.  The
+// limits on the integral and derivative terms are left at the defaults.
+func ProposeGains(p Plant) (absolute, relative headingholder.Gains) {
+	ku, tu := p.UltimateGain(), p.UltimatePeriod().Seconds()
+
+	absolute = headingholder.DefaultAbsoluteGains()
+	absolute.Kp = 0.2 * ku
+	absolute.Ki = absolute.Kp / (tu / 2)
+	absolute.Kd = absolute.Kp * tu / 3
+
+	relative = headingholder.DefaultRelativeGains()
+	relative.Kp = 0.6 * ku
+	relative.Ki = relative.Kp / (tu / 2)
+	relative.Kd = relative.Kp * tu / 8
+	return
+}
+
+// StepResponse summarises the response to a change of target heading.
+type StepResponse struct {
+	// RiseTime is the time taken to go from 10% to 90% of the way to the target.
+	RiseTime         time.Duration
+	OvershootDegrees float64
+	// SettlingTime is when the heading last came within SettleBandDegrees of the target; Settled is
+	// false if it didn't stay there until the end of the samples.
+	SettlingTime time.Duration
+	Settled      bool
+}
+
+const SettleBandDegrees = 2
+
+// AnalyseStep summarises the response to a step from fromDegrees to toDegrees at time 0.
+func AnalyseStep(samples []Sample, fromDegrees, toDegrees float64) StepResponse {
+	var r StepResponse
+	step := toDegrees - fromDegrees
+	if step == 0 || len(samples) == 0 {
+		return r
+	}
+
+	var t10, t90 time.Duration
+	var seen10, seen90 bool
+	for _, s := range samples {
+		// Progress towards the target, as a fraction of the step.
+		progress := (s.HeadingDegrees - fromDegrees) / step
+		if !seen10 && progress >= 0.1 {
+			t10, seen10 = s.Time, true
+		}
+		if !seen90 && progress >= 0.9 {
+			t90, seen90 = s.Time, true
+		}
+		r.OvershootDegrees = max(r.OvershootDegrees, (progress-1)*math.Abs(step))
+		if math.Abs(s.HeadingDegrees-toDegrees) > SettleBandDegrees {
+			r.SettlingTime = s.Time
+			r.Settled = false
+		} else if !r.Settled {
+			r.SettlingTime = s.Time
+			r.Settled = true
+		}
+	}
+	if seen90 {
+		r.RiseTime = t90 - t10
+	}
+	return r
+}
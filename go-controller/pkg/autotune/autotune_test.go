@@ -0,0 +1,96 @@
+package autotune
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder/angle"
+)
+
+// simulateRelay runs a relay experiment against an integrator with a dead time.
+func simulateRelay(gain float64, deadTime time.Duration, relayDegreesPerS, hysteresis float64, d time.Duration) []Sample {
+	relay := Relay{CentreDegrees: 10, HysteresisDegrees: hysteresis}
+	const step = time.Millisecond
+	delaySteps := int(deadTime / step)
+	var commands []float64
+	heading := 0.0
+	var samples []Sample
+	for t := time.Duration(0); t < d; t += step {
+		command := -relayDegreesPerS
+		if relay.Update(heading) {
+			command = relayDegreesPerS
+		}
+		commands = append(commands, command)
+		if len(commands) > delaySteps {
+			heading += gain * commands[len(commands)-1-delaySteps] * step.Seconds()
+		}
+		samples = append(samples, Sample{Time: t, HeadingDegrees: heading})
+	}
+	return samples
+}
+
+func TestRelayIdentifiesPlant(t *testing.T) {
+	samples := simulateRelay(0.8, 80*time.Millisecond, 100, 2, 5*time.Second)
+	r, err := AnalyseRelay(samples, 10, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Period = 4 dead times + 4 * hysteresis / rate; amplitude = hysteresis + rate * dead time.
+	if r.Period < 410*time.Millisecond || r.Period > 430*time.Millisecond {
+		t.Errorf("Unexpected period %v", r.Period)
+	}
+	if math.Abs(r.AmplitudeDegrees-8.4) > 0.2 {
+		t.Errorf("Unexpected amplitude %.2f", r.AmplitudeDegrees)
+	}
+
+	p := IdentifyPlant(r, 100, 2)
+	if math.Abs(p.Gain-0.8) > 0.05 || p.DeadTime < 77*time.Millisecond || p.DeadTime > 83*time.Millisecond {
+		t.Errorf("Failed to identify plant: %+v", p)
+	}
+
+	absolute, relative := ProposeGains(p)
+	if absolute.Kp <= 0 || absolute.Ki <= 0 || absolute.Kd <= 0 {
+		t.Errorf("Expected positive gains: %v", absolute)
+	}
+	if relative.Kp <= absolute.Kp {
+		t.Errorf("Expected the yaw rate controller to be more aggressive: %v vs %v", relative, absolute)
+	}
+}
+
+func TestRelayTooShort(t *testing.T) {
+	samples := simulateRelay(1, 80*time.Millisecond, 100, 2, 1200*time.Millisecond)
+	if _, err := AnalyseRelay(samples, 10, time.Second); err != ErrTooFewCycles {
+		t.Fatalf("Expected ErrTooFewCycles, got %v", err)
+	}
+}
+
+func TestStepResponse(t *testing.T) {
+	var rec Recorder
+	start := time.Unix(1000, 0)
+	// Turn from 170 through ±180 to a 20 degree step, overshooting by 5 degrees.
+	for i, h := range []float64{170, 170, 175, 180, 185, 190, 195, 192, 190, 190} {
+		rec.Add(start.Add(time.Duration(i)*100*time.Millisecond), angle.FromFloat(h))
+	}
+	r := AnalyseStep(rec.Samples, 0, 20)
+	if r.RiseTime != 300*time.Millisecond {
+		t.Errorf("Unexpected rise time %v", r.RiseTime)
+	}
+	if math.Abs(r.OvershootDegrees-5) > 1e-9 {
+		t.Errorf("Unexpected overshoot %v", r.OvershootDegrees)
+	}
+	if !r.Settled || r.SettlingTime != 700*time.Millisecond {
+		t.Errorf("Unexpected settling %+v", r)
+	}
+
+	path := filepath.Join(t.TempDir(), "step.png")
+	err := Plot(path, []Panel{{Title: "Step", Traces: []Trace{{Name: "test", Samples: rec.Samples}}, TargetDegrees: []float64{20}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+}
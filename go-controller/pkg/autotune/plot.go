@@ -0,0 +1,121 @@
+package autotune
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// Trace is one line on a plot.
+type Trace struct {
+	Name string
+	// Colour defaults to one from a palette.
+	Colour  color.Color
+	Samples []Sample
+}
+
+// Panel is one graph of heading against time.
+type Panel struct {
+	Title  string
+	Traces []Trace
+	// TargetDegrees are drawn as horizontal lines, for reference.
+	TargetDegrees []float64
+}
+
+var palette = []color.Color{
+	color.RGBA{0x1f, 0x77, 0xb4, 0xff},
+	color.RGBA{0xd6, 0x27, 0x28, 0xff},
+	color.RGBA{0x2c, 0xa0, 0x2c, 0xff},
+	color.RGBA{0xff, 0x7f, 0x0e, 0xff},
+}
+
+const (
+	plotWidth       = 1200
+	plotPanelHeight = 400
+	plotMargin      = 50
+)
+
+// Plot draws the panels one above the other and saves them as a PNG.
+func Plot(path string, panels []Panel) error {
+	dc := gg.NewContext(plotWidth, plotPanelHeight*len(panels))
+	dc.SetColor(color.White)
+	dc.Clear()
+	for i, p := range panels {
+		dc.Push()
+		dc.Translate(0, float64(i*plotPanelHeight))
+		drawPanel(dc, p)
+		dc.Pop()
+	}
+	return dc.SavePNG(path)
+}
+
+func drawPanel(dc *gg.Context, p Panel) {
+	// Work out the extent of the data so that it fills the panel.
+	var maxTime time.Duration
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, tr := range p.Traces {
+		for _, s := range tr.Samples {
+			maxTime = max(maxTime, s.Time)
+			lo = min(lo, s.HeadingDegrees)
+			hi = max(hi, s.HeadingDegrees)
+		}
+	}
+	for _, t := range p.TargetDegrees {
+		lo = min(lo, t)
+		hi = max(hi, t)
+	}
+	if maxTime == 0 || math.IsInf(lo, 0) {
+		return
+	}
+	if hi-lo < 1 {
+		lo, hi = lo-0.5, hi+0.5
+	}
+	left, right := float64(plotMargin), float64(plotWidth-plotMargin)
+	top, bottom := float64(plotMargin), float64(plotPanelHeight-plotMargin)
+	x := func(t time.Duration) float64 {
+		return left + (right-left)*t.Seconds()/maxTime.Seconds()
+	}
+	y := func(degrees float64) float64 {
+		return bottom - (bottom-top)*(degrees-lo)/(hi-lo)
+	}
+
+	// Axes and labels.
+	dc.SetColor(color.Black)
+	dc.SetLineWidth(1)
+	dc.DrawRectangle(left, top, right-left, bottom-top)
+	dc.Stroke()
+	dc.DrawStringAnchored(p.Title, plotWidth/2, top/2, 0.5, 0.5)
+	dc.DrawStringAnchored(fmt.Sprintf("%.1f°", hi), left-5, top, 1, 0.5)
+	dc.DrawStringAnchored(fmt.Sprintf("%.1f°", lo), left-5, bottom, 1, 0.5)
+	dc.DrawStringAnchored("0s", left, bottom+15, 0.5, 0.5)
+	dc.DrawStringAnchored(fmt.Sprintf("%.2fs", maxTime.Seconds()), right, bottom+15, 0.5, 0.5)
+
+	dc.SetRGB(0.6, 0.6, 0.6)
+	dc.SetDash(4, 4)
+	for _, t := range p.TargetDegrees {
+		dc.DrawLine(left, y(t), right, y(t))
+		dc.Stroke()
+	}
+	dc.SetDash()
+
+	for i, tr := range p.Traces {
+		if tr.Colour != nil {
+			dc.SetColor(tr.Colour)
+		} else {
+			dc.SetColor(palette[i%len(palette)])
+		}
+		dc.SetLineWidth(2)
+		for j, s := range tr.Samples {
+			if j == 0 {
+				dc.MoveTo(x(s.Time), y(s.HeadingDegrees))
+			} else {
+				dc.LineTo(x(s.Time), y(s.HeadingDegrees))
+			}
+		}
+		dc.Stroke()
+		dc.DrawString(tr.Name, right-250, top+20+float64(i)*15)
+	}
+}
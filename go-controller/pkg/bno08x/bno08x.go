@@ -7,6 +7,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder/angle"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/telemetry"
 	"gonum.org/v1/gonum/spatial/r3"
 	"io"
 	"math"
@@ -40,6 +41,19 @@ func (i IMUReport) String() string {
 		float64(i.XAccel)/100.0, float64(i.YAccel)/100.0, float64(i.ZAccel)/100.0)
 }
 
+// Telemetry converts the report to the flight recorder's format.
+func (i IMUReport) Telemetry() telemetry.IMU {
+	return telemetry.IMU{
+		Index:  i.Index,
+		Yaw:    i.Yaw,
+		Pitch:  i.Pitch,
+		Roll:   i.Roll,
+		XAccel: i.XAccel,
+		YAccel: i.YAccel,
+		ZAccel: i.ZAccel,
+	}
+}
+
 func (i IMUReport) YawDegrees() float64 {
 	return (float64(i.Yaw)) / 100.0
 }
@@ -188,6 +202,7 @@ resync:
 }
 
 func (b *BNO08X) setReport(report IMUReport) {
+	telemetry.RecordIMU(report.Time, report.Telemetry())
 	b.lock.Lock()
 	defer b.lock.Unlock()
 	b.lastReport = report
@@ -1,78 +1,81 @@
 package challengemode
 
-import "github.com/tigerbot-team/tigerbot/go-controller/pkg/chassis"
+import (
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/chassis"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/kinematics"
+)
 
 var mmPerRotation = [72]struct{ ahead, left float64 }{ // To be filled in properly
-	{-chassis.WheelCircumMM / 4, 0},         // -180
-	{0, 0},                                  // -175
-	{0, 0},                                  // -170
-	{0, 0},                                  // -165
-	{0, 0},                                  // -160
-	{0, 0},                                  // -155
-	{0, 0},                                  // -150
-	{0, 0},                                  // -145
-	{0, 0},                                  // -140
-	{0, 0},                                  // -135
-	{0, 0},                                  // -130
-	{0, 0},                                  // -125
-	{0, 0},                                  // -120
-	{0, 0},                                  // -115
-	{0, 0},                                  // -110
-	{0, 0},                                  // -105
-	{0, 0},                                  // -100
-	{0, 0},                                  // -95
-	{0, -chassis.WheelCircumMM * 1.044 / 4}, // -90
-	{0, 0},                                  // -85
-	{0, 0},                                  // -80
-	{0, 0},                                  // -75
-	{0, 0},                                  // -70
-	{0, 0},                                  // -65
-	{0, 0},                                  // -60
-	{0, 0},                                  // -55
-	{0, 0},                                  // -50
-	{0, 0},                                  // -45
-	{0, 0},                                  // -40
-	{0, 0},                                  // -35
-	{0, 0},                                  // -30
-	{0, 0},                                  // -25
-	{0, 0},                                  // -20
-	{0, 0},                                  // -15
-	{0, 0},                                  // -10
-	{0, 0},                                  // -5
-	{chassis.WheelCircumMM / 4, 0},          // 0
-	{0, 0},                                  // +5
-	{0, 0},                                  // +10
-	{0, 0},                                  // +15
-	{0, 0},                                  // +20
-	{0, 0},                                  // +25
-	{0, 0},                                  // +30
-	{0, 0},                                  // +35
-	{0, 0},                                  // +40
-	{0, 0},                                  // +45
-	{0, 0},                                  // +50
-	{0, 0},                                  // +55
-	{0, 0},                                  // +60
-	{0, 0},                                  // +65
-	{0, 0},                                  // +70
-	{0, 0},                                  // +75
-	{0, 0},                                  // +80
-	{0, 0},                                  // +85
-	{0, chassis.WheelCircumMM * 1.044 / 4},  // +90
-	{0, 0},                                  // +95
-	{0, 0},                                  // +100
-	{0, 0},                                  // +105
-	{0, 0},                                  // +110
-	{0, 0},                                  // +115
-	{0, 0},                                  // +120
-	{0, 0},                                  // +125
-	{0, 0},                                  // +130
-	{0, 0},                                  // +135
-	{0, 0},                                  // +140
-	{0, 0},                                  // +145
-	{0, 0},                                  // +150
-	{0, 0},                                  // +155
-	{0, 0},                                  // +160
-	{0, 0},                                  // +165
-	{0, 0},                                  // +170
-	{0, 0},                                  // +175
+	{-chassis.WheelCircumMM / 4, 0}, // -180
+	{0, 0},                          // -175
+	{0, 0},                          // -170
+	{0, 0},                          // -165
+	{0, 0},                          // -160
+	{0, 0},                          // -155
+	{0, 0},                          // -150
+	{0, 0},                          // -145
+	{0, 0},                          // -140
+	{0, 0},                          // -135
+	{0, 0},                          // -130
+	{0, 0},                          // -125
+	{0, 0},                          // -120
+	{0, 0},                          // -115
+	{0, 0},                          // -110
+	{0, 0},                          // -105
+	{0, 0},                          // -100
+	{0, 0},                          // -95
+	{0, -chassis.WheelCircumMM * kinematics.MecFac / 4}, // -90
+	{0, 0},                         // -85
+	{0, 0},                         // -80
+	{0, 0},                         // -75
+	{0, 0},                         // -70
+	{0, 0},                         // -65
+	{0, 0},                         // -60
+	{0, 0},                         // -55
+	{0, 0},                         // -50
+	{0, 0},                         // -45
+	{0, 0},                         // -40
+	{0, 0},                         // -35
+	{0, 0},                         // -30
+	{0, 0},                         // -25
+	{0, 0},                         // -20
+	{0, 0},                         // -15
+	{0, 0},                         // -10
+	{0, 0},                         // -5
+	{chassis.WheelCircumMM / 4, 0}, // 0
+	{0, 0},                         // +5
+	{0, 0},                         // +10
+	{0, 0},                         // +15
+	{0, 0},                         // +20
+	{0, 0},                         // +25
+	{0, 0},                         // +30
+	{0, 0},                         // +35
+	{0, 0},                         // +40
+	{0, 0},                         // +45
+	{0, 0},                         // +50
+	{0, 0},                         // +55
+	{0, 0},                         // +60
+	{0, 0},                         // +65
+	{0, 0},                         // +70
+	{0, 0},                         // +75
+	{0, 0},                         // +80
+	{0, 0},                         // +85
+	{0, chassis.WheelCircumMM * kinematics.MecFac / 4}, // +90
+	{0, 0}, // +95
+	{0, 0}, // +100
+	{0, 0}, // +105
+	{0, 0}, // +110
+	{0, 0}, // +115
+	{0, 0}, // +120
+	{0, 0}, // +125
+	{0, 0}, // +130
+	{0, 0}, // +135
+	{0, 0}, // +140
+	{0, 0}, // +145
+	{0, 0}, // +150
+	{0, 0}, // +155
+	{0, 0}, // +160
+	{0, 0}, // +165
+	{0, 0}, // +170
+	{0, 0}, // +175
 }
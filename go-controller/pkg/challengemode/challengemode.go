@@ -12,17 +12,27 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/tigerbot-team/tigerbot/go-controller/pkg/chassis"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/hardware"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder/angle"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/joystick"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/kinematics"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/telemetry"
 )
 
 const (
 	// Total bot dimensions.
 	dxBot = float64(0)
 	dyBot = float64(0)
+
+	// Profile for the turns between moves, in degrees/s and degrees/s².
+	turnMaxRate  = 120
+	turnMaxAccel = 360
+	// How closely and for how long a turn has to settle before we move off.
+	turnToleranceDegrees = 1
+	turnSettleTime       = 100 * time.Millisecond
+	turnTimeout          = 3 * time.Second
 )
 
 // Absolute HH heading value that corresponds to the current arena's
@@ -62,8 +72,16 @@ type Challenge interface {
 	SpeedMMPerS() float64
 }
 
+// Display shows the position estimate and target to the pit crew; the dashboard's Extras implements
+// it.
+type Display interface {
+	Set(key string, value any)
+	Clear(key string)
+}
+
 type ChallengeMode struct {
-	hw hardware.Interface
+	hw      hardware.Interface
+	display Display
 
 	cancel         context.CancelFunc
 	startWG        sync.WaitGroup
@@ -81,11 +99,14 @@ type ChallengeMode struct {
 	lastThrottleAngle float64 // CCW from bot-relative straight ahead
 
 	rotationsBeforeMotion picobldc.PerMotorVal[float64]
+	// lastPositionUpdate is when we last updated the position; we check for wheel slip since then.
+	lastPositionUpdate time.Time
 }
 
-func New(hw hardware.Interface, challenge Challenge) *ChallengeMode {
+func New(hw hardware.Interface, display Display, challenge Challenge) *ChallengeMode {
 	m := &ChallengeMode{
 		hw:             hw,
+		display:        display,
 		joystickEvents: make(chan *joystick.Event),
 		challenge:      challenge,
 		name:           challenge.Name(),
@@ -113,6 +134,8 @@ func (m *ChallengeMode) Start(ctx context.Context) {
 func (m *ChallengeMode) Stop() {
 	m.cancel()
 	m.stopWG.Wait()
+	m.display.Clear("position")
+	m.display.Clear("target")
 }
 
 func (m *ChallengeMode) loop(ctx context.Context) {
@@ -154,6 +177,10 @@ func (m *ChallengeMode) startSequence() {
 		m.log("Already running")
 		return
 	}
+	if battery := m.hw.BatteryStatus(); !battery.AutonomousAllowed() {
+		m.log("Battery too low to start sequence: %v", battery.Level)
+		return
+	}
 
 	m.log("Starting sequence...")
 	m.running = true
@@ -177,6 +204,7 @@ func (m *ChallengeMode) runSequence(ctx context.Context) {
 	// We use the absolute heading hold mode so we can do things
 	// like "turn right 90 degrees".
 	hh := m.hw.StartHeadingHoldMode()
+	m.hw.UseMotionLimits(hardware.LimitsChallenge)
 
 	// Get initial (believed) position - determined by the
 	// challenge.  We don't have a target yet.
@@ -204,6 +232,7 @@ func (m *ChallengeMode) runSequence(ctx context.Context) {
 	screen.ClearNotice("Ready!")
 
 	startTime := time.Now()
+	m.lastPositionUpdate = startTime
 
 	iterationCount := 0
 
@@ -235,6 +264,10 @@ func (m *ChallengeMode) runSequence(ctx context.Context) {
 		}
 		m.log("Iteration %v: position %#v", iterationCount, *position)
 		m.log("Iteration %v: target %#v moveTime %v", iterationCount, *target, moveTime)
+		telemetry.RecordPosition(position.X, position.Y, position.Heading)
+		telemetry.RecordTarget(target.X, target.Y, target.Heading, moveTime, target.Stop)
+		m.display.Set("position", *position)
+		m.display.Set("target", *target)
 
 		// Start moving to the target position.  Note, sets
 		// m.lastThrottleAngle.
@@ -327,6 +360,8 @@ func DisplacementsByTable(log Log, normalizedAngle, bl, br, fl, fr float64) (ahe
 
 var CheckAssumptions = false
 
+// Displacements is a more careful version of kinematics.Model.Motion for when we know the
+// direction that the bot was going in.
 func Displacements(log Log, normalizedAngle, bl, br, fl, fr float64) (ahead, left float64) {
 	// If F = forwards throttle (+tive ahead) and S = sideways
 	// throttle (+tive left):
@@ -347,7 +382,8 @@ func Displacements(log Log, normalizedAngle, bl, br, fl, fr float64) (ahead, lef
 	flminusbr := fl - br
 	log("blminusfr %v", blminusfr)
 	log("flminusbr %v", flminusbr)
-	const k = float64(1.044)
+	model := kinematics.Default()
+	k := model.SidewaysSlip
 	use := func(string) {}  // no-op
 	expect := func(bool) {} // no-op
 	if CheckAssumptions {
@@ -585,7 +621,7 @@ func Displacements(log Log, normalizedAngle, bl, br, fl, fr float64) (ahead, lef
 
 	log("F %v S %v", F, S)
 
-	return chassis.WheelCircumMM * F, chassis.WheelCircumMM * S / k
+	return model.WheelCircumMM * F, model.WheelCircumMM * S / k
 }
 
 func (m *ChallengeMode) UpdatePosition(position *Position) {
@@ -612,10 +648,22 @@ func (m *ChallengeMode) UpdatePosition(position *Position) {
 	m.log("aheadDisplacement %v", aheadDisplacement)
 	m.log("leftDisplacement %v", leftDisplacement)
 
+	// If the wheels slipped, they turned further than we moved; trust them less.
+	slip := m.hw.WheelSlipSince(m.lastPositionUpdate)
+	m.lastPositionUpdate = time.Now()
+	if slip.Slipping {
+		m.log("wheels slipped (odometry yaw %.1f, IMU yaw %.1f), confidence %.2f",
+			slip.OdometryYawDegrees, slip.IMUYawDegrees, slip.Confidence)
+		aheadDisplacement *= slip.Confidence
+		leftDisplacement *= slip.Confidence
+	}
+
 	dx, dy := AbsoluteDeltas(position.Heading, aheadDisplacement, leftDisplacement)
 	position.X += dx
 	position.Y += dy
 	m.log("position after movement %#v", *position)
+	telemetry.RecordPosition(position.X, position.Y, position.Heading)
+	m.display.Set("position", *position)
 }
 
 // Given a `botHeading` (CCW relative to +tive X axis) and distances
@@ -641,9 +689,21 @@ func (m *ChallengeMode) StartMotion(
 
 	if target.Heading != current.Heading {
 		m.log("Heading change %v -> %v", current.Heading, target.Heading)
-		hh.SetHeading(calibratedXHeading + target.Heading*PositiveAnglesAnticlockwise)
-		hh.Wait(ctx)
-		current.Heading = target.Heading
+		hh.SetHeadingWithProfile(calibratedXHeading+target.Heading*PositiveAnglesAnticlockwise, turnMaxRate, turnMaxAccel)
+		result := hh.WaitSettled(ctx, turnToleranceDegrees, turnSettleTime, turnTimeout)
+		m.log("Turn %v", result)
+		switch result.Outcome {
+		case headingholder.Cancelled:
+			return
+		case headingholder.Settled:
+			current.Heading = target.Heading
+		default:
+			// We're not where we wanted to be; track the heading that we actually have so that
+			// the move goes in the right direction.  Keep it within 180 degrees of the target so
+			// that it compares with the challenge's headings, which can be outside (-180, 180].
+			actual := (m.hw.CurrentHeading().Float() - calibratedXHeading) / PositiveAnglesAnticlockwise
+			current.Heading = target.Heading + angle.FromFloat(actual-target.Heading).Float()
+		}
 	}
 
 	if target.Stop {
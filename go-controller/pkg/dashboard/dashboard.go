@@ -0,0 +1,223 @@
+// Package dashboard serves a web page showing the robot's live state, for the pit crew to watch from a
+// laptop.  The page is fed by a server-sent events stream; /state returns a single JSON snapshot.
+package dashboard
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/hardware"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder/angle"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/screen"
+)
+
+const (
+	DefaultAddr = ":8080"
+
+	updateInterval = 200 * time.Millisecond
+	tofWaitTimeout = 10 * time.Millisecond
+)
+
+//go:embed index.html
+var indexHTML []byte
+
+// Extras holds mode-specific values, such as challengemode's position estimate, for the dashboard to
+// show.  It's handed to the modes that publish values when they're created.
+type Extras struct {
+	lock   sync.Mutex
+	values map[string]any
+}
+
+func NewExtras() *Extras {
+	return &Extras{values: map[string]any{}}
+}
+
+// Set publishes a value.  The value is JSON-encoded so it should be a copy rather than something
+// that the mode goes on to modify.
+func (e *Extras) Set(key string, value any) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.values[key] = value
+}
+
+// Clear removes a value published with Set.
+func (e *Extras) Clear(key string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	delete(e.values, key)
+}
+
+type State struct {
+	Time time.Time
+
+	Mode          string
+	Heading       float64
+	TargetHeading *float64 `json:",omitempty"`
+
+	Rotations map[string]float64
+	WheelRPS  map[string]float64
+	Stalled   map[string]bool
+
+	ToFs     []ToFReading
+	ToFError string `json:",omitempty"`
+
+	BusVoltages []float64
+	Battery     hardware.BatteryStatus
+	Thermal     hardware.ThermalStatus
+	PicoFaults  []hardware.PicoFault
+	Notices     map[string]screen.NoticeLevel
+
+	Extras map[string]any
+}
+
+type ToFReading struct {
+	Name   string
+	MM     int
+	Health string
+	AgeMS  int64
+}
+
+var (
+	// In the same order as hardware.DistanceReadings.
+	tofNames   = []string{"LR", "LF", "FL", "FR", "RF", "RR"}
+	wheelNames = map[int]string{
+		picobldc.FrontLeft:  "FL",
+		picobldc.FrontRight: "FR",
+		picobldc.BackLeft:   "BL",
+		picobldc.BackRight:  "BR",
+	}
+)
+
+type targetHeadingSource interface {
+	TargetHeading() (angle.PlusMinus180, bool)
+}
+
+func snapshot(ctx context.Context, hw hardware.Interface, extras *Extras) State {
+	scr := screen.Snapshot()
+	s := State{
+		Time:        time.Now(),
+		Mode:        scr.Mode,
+		Heading:     hw.CurrentHeading().Float(),
+		Rotations:   map[string]float64{},
+		WheelRPS:    map[string]float64{},
+		Stalled:     map[string]bool{},
+		BusVoltages: scr.BusVoltages,
+		Battery:     hw.BatteryStatus(),
+		Thermal:     hw.ThermalStatus(),
+		PicoFaults:  hw.PicoFaults(),
+		Notices:     scr.Notices,
+		Extras:      map[string]any{},
+	}
+
+	if ths, ok := hw.(targetHeadingSource); ok {
+		if target, ok := ths.TargetHeading(); ok {
+			f := target.Float()
+			s.TargetHeading = &f
+		}
+	}
+
+	rotations := hw.AccumulatedRotations()
+	velocities := hw.WheelVelocities()
+	for m, name := range wheelNames {
+		s.Rotations[name] = rotations[m]
+		s.WheelRPS[name] = velocities.RPS[m]
+		s.Stalled[name] = velocities.Stalled[m]
+	}
+
+	tofCtx, cancel := context.WithTimeout(ctx, tofWaitTimeout)
+	defer cancel()
+	readings, err := hw.WaitForDistanceReadings(tofCtx, hardware.RevCurrent)
+	if err != nil {
+		s.ToFError = err.Error()
+	}
+	for i, r := range readings.Readings {
+		name := fmt.Sprint(i)
+		if i < len(tofNames) {
+			name = tofNames[i]
+		}
+		s.ToFs = append(s.ToFs, ToFReading{
+			Name:   name,
+			MM:     r.DistanceMM,
+			Health: r.Health.String(),
+			AgeMS:  r.Age().Milliseconds(),
+		})
+	}
+
+	extras.lock.Lock()
+	for k, v := range extras.values {
+		s.Extras[k] = v
+	}
+	extras.lock.Unlock()
+
+	return s
+}
+
+// Serve runs the dashboard's HTTP server until the context is cancelled.
+func Serve(ctx context.Context, addr string, hw hardware.Interface, extras *Extras) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(indexHTML)
+	})
+	mux.HandleFunc("/state", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(snapshot(req.Context(), hw, extras))
+	})
+	mux.HandleFunc("/events", func(w http.ResponseWriter, req *http.Request) {
+		streamEvents(w, req, hw, extras)
+	})
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Println("Dashboard: serving on", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Println("Dashboard: server failed", err)
+	}
+}
+
+func streamEvents(w http.ResponseWriter, req *http.Request, hw hardware.Interface, extras *Extras) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	ticker := time.NewTicker(updateInterval)
+	defer ticker.Stop()
+	for {
+		data, err := json.Marshal(snapshot(req.Context(), hw, extras))
+		if err != nil {
+			fmt.Println("Dashboard: failed to encode state", err)
+			return
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		select {
+		case <-req.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,365 @@
+package hardware
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/kinematics"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/screen"
+)
+
+const (
+	batteryConfigFile      = "/cfg/battery.yaml"
+	batteryConfigInUseFile = "/cfg/battery-in-use.yaml"
+
+	// Enough history for the post-run report; we read the power monitors once a second.
+	batteryHistoryLen = 600
+	// The voltage sags when the motors draw a burst of current so we act on the median of the last
+	// few readings rather than on any one reading.
+	batteryFilterLen = 5
+	// Below this the bus isn't powered at all, for example the traction battery is unplugged on the
+	// bench.  That isn't a flat battery.
+	batteryDisconnectedCellVolts = 2.0
+)
+
+// BatteryConfig is the undervoltage policy.  The thresholds are per cell, after correcting for the
+// sag due to the current being drawn.  Each level applies once the voltage drops below its threshold
+// and stays in force until the voltage recovers above threshold+HysteresisCellVolts; LiPos bounce
+// back a little when the load is removed so, without that, we'd cycle in and out of each level.
+type BatteryConfig struct {
+	LowCellVolts          float64 // Warn.
+	CapCellVolts          float64 // Cap the throttle to ThrottleCapRPS.
+	NoAutonomousCellVolts float64 // Refuse to start autonomous sequences.
+	StopCellVolts         float64 // Stop the motors.
+	HysteresisCellVolts   float64
+
+	ThrottleCapRPS float64
+	// InternalOhms is the resistance of each pack plus wiring, used to estimate the resting voltage
+	// from the voltage under load.
+	InternalOhms float64
+}
+
+func DefaultBatteryConfig() BatteryConfig {
+	return BatteryConfig{
+		LowCellVolts:          3.6,
+		CapCellVolts:          3.5,
+		NoAutonomousCellVolts: 3.4,
+		StopCellVolts:         3.3,
+		HysteresisCellVolts:   0.2,
+		ThrottleCapRPS:        1.5,
+		InternalOhms:          0.05,
+	}
+}
+
+func loadBatteryConfig() BatteryConfig {
+	config := DefaultBatteryConfig()
+	cfg, err := ioutil.ReadFile(batteryConfigFile)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		err = yaml.Unmarshal(cfg, &config)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	// Write out the config that we are using.
+	fmt.Printf("Battery: Using config: %#v\n", config)
+	cfgBytes, err := yaml.Marshal(&config)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		err = ioutil.WriteFile(batteryConfigInUseFile, cfgBytes, 0666)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	return config
+}
+
+// BatteryBus identifies a power monitor, and the battery that it measures.  It's the index of the
+// bus in BatteryStatus.Buses.
+type BatteryBus int
+
+const (
+	BatteryBusPi BatteryBus = iota
+	BatteryBusTraction
+)
+
+type BatteryLevel int
+
+const (
+	BatteryOK BatteryLevel = iota
+	BatteryLow
+	BatteryThrottleCapped
+	BatteryNoAutonomous
+	BatteryFlat // Motors stopped.
+)
+
+func (l BatteryLevel) String() string {
+	switch l {
+	case BatteryOK:
+		return "ok"
+	case BatteryLow:
+		return "low"
+	case BatteryThrottleCapped:
+		return "throttle-capped"
+	case BatteryNoAutonomous:
+		return "no-autonomous"
+	case BatteryFlat:
+		return "flat"
+	}
+	return fmt.Sprintf("BatteryLevel(%d)", int(l))
+}
+
+// Screen notices and sounds for each level.
+var (
+	batteryNotices = map[BatteryLevel]string{
+		BatteryLow:            "BATT LOW",
+		BatteryThrottleCapped: "BATT SLOW",
+		BatteryNoAutonomous:   "BATT NO AUTO",
+		BatteryFlat:           "BATT FLAT",
+	}
+	batterySounds = map[BatteryLevel]string{
+		BatteryLow:            "/sounds/batterylow.wav",
+		BatteryThrottleCapped: "/sounds/batterylow.wav",
+		BatteryNoAutonomous:   "/sounds/batterycritical.wav",
+		BatteryFlat:           "/sounds/batteryflat.wav",
+	}
+)
+
+type PowerReading struct {
+	Time  time.Time
+	Volts float64
+	Amps  float64
+	Watts float64
+}
+
+type BusStatus struct {
+	Name      string
+	Cells     int
+	Connected bool
+	Latest    PowerReading
+	// RestingCellVolts is the filtered per-cell voltage, corrected for the load.
+	RestingCellVolts float64
+	// StateOfCharge is an estimate, from 0 to 1, based on RestingCellVolts.
+	StateOfCharge float64
+	Level         BatteryLevel
+	History       []PowerReading `json:"-"` // Oldest first.
+}
+
+type BatteryStatus struct {
+	Buses []BusStatus
+	// Level is the worst level of any bus; it's the one that the policy enforces.
+	Level BatteryLevel
+}
+
+// AutonomousAllowed returns false if the batteries are too low to start an autonomous sequence.
+func (s BatteryStatus) AutonomousAllowed() bool {
+	return s.Level < BatteryNoAutonomous
+}
+
+// batteryMonitor tracks the power monitor readings for each bus and decides which level of the
+// undervoltage policy applies.
+type batteryMonitor struct {
+	config BatteryConfig
+
+	lock  sync.Mutex
+	buses []*busMonitor
+}
+
+type busMonitor struct {
+	name    string
+	cells   int
+	history []PowerReading
+	status  BusStatus
+}
+
+// newBatteryMonitor creates a monitor for the buses with the given names and cell counts, which are
+// indexed by BatteryBus.
+func newBatteryMonitor(config BatteryConfig, names []string, cells []int) *batteryMonitor {
+	b := &batteryMonitor{config: config}
+	for i := range names {
+		b.buses = append(b.buses, &busMonitor{
+			name:  names[i],
+			cells: cells[i],
+		})
+	}
+	return b
+}
+
+func (b *batteryMonitor) record(bus BatteryBus, r PowerReading) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if bus < 0 || int(bus) >= len(b.buses) {
+		return
+	}
+	m := b.buses[bus]
+
+	m.history = append(m.history, r)
+	if len(m.history) > batteryHistoryLen {
+		m.history = m.history[len(m.history)-batteryHistoryLen:]
+	}
+
+	var recent []float64
+	for _, h := range m.history[max(0, len(m.history)-batteryFilterLen):] {
+		recent = append(recent, (h.Volts+h.Amps*b.config.InternalOhms)/float64(m.cells))
+	}
+	sort.Float64s(recent)
+	cellVolts := recent[len(recent)/2]
+
+	m.status.Latest = r
+	m.status.RestingCellVolts = cellVolts
+	m.status.Connected = cellVolts > batteryDisconnectedCellVolts
+	if !m.status.Connected {
+		m.status.StateOfCharge = 0
+		m.status.Level = BatteryOK
+		return
+	}
+	m.status.StateOfCharge = lipoStateOfCharge(cellVolts)
+	m.status.Level = b.levelFor(m.status.Level, cellVolts)
+}
+
+// levelFor works out the policy level for the given voltage, taking the hysteresis into account.
+func (b *batteryMonitor) levelFor(current BatteryLevel, cellVolts float64) BatteryLevel {
+	thresholds := []float64{
+		BatteryLow:            b.config.LowCellVolts,
+		BatteryThrottleCapped: b.config.CapCellVolts,
+		BatteryNoAutonomous:   b.config.NoAutonomousCellVolts,
+		BatteryFlat:           b.config.StopCellVolts,
+	}
+	level := BatteryOK
+	for l := BatteryLow; l <= BatteryFlat; l++ {
+		threshold := thresholds[l]
+		if l <= current {
+			threshold += b.config.HysteresisCellVolts
+		}
+		if cellVolts < threshold {
+			level = l
+		}
+	}
+	return level
+}
+
+// level returns the worst level of any bus.
+func (b *batteryMonitor) level() BatteryLevel {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	level := BatteryOK
+	for _, m := range b.buses {
+		level = max(level, m.status.Level)
+	}
+	return level
+}
+
+func (b *batteryMonitor) Status() BatteryStatus {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	var s BatteryStatus
+	for _, m := range b.buses {
+		bs := m.status
+		bs.Name = m.name
+		bs.Cells = m.cells
+		bs.History = append([]PowerReading(nil), m.history...)
+		s.Buses = append(s.Buses, bs)
+		s.Level = max(s.Level, bs.Level)
+	}
+	return s
+}
+
+// limitSpeeds applies the policy to a set of motor speeds.  When the throttle is capped, all the
+// speeds are scaled down together so that the bot still goes in the same direction.
+func (b *batteryMonitor) limitSpeeds(fl, fr, bl, br int16) (int16, int16, int16, int16) {
+	level := b.level()
+	if level >= BatteryFlat {
+		return 0, 0, 0, 0
+	}
+	if level < BatteryThrottleCapped {
+		return fl, fr, bl, br
+	}
+	return kinematics.LimitMotorSpeeds(fl, fr, bl, br, b.config.ThrottleCapRPS)
+}
+
+// lipoCurve maps the resting voltage of a LiPo cell to its state of charge.
+var lipoCurve = []struct{ volts, soc float64 }{
+	{3.27, 0},
+	{3.61, 0.05},
+	{3.69, 0.10},
+	{3.71, 0.15},
+	{3.73, 0.20},
+	{3.75, 0.25},
+	{3.77, 0.30},
+	{3.79, 0.35},
+	{3.80, 0.40},
+	{3.82, 0.45},
+	{3.84, 0.50},
+	{3.85, 0.55},
+	{3.87, 0.60},
+	{3.91, 0.65},
+	{3.95, 0.70},
+	{3.98, 0.75},
+	{4.02, 0.80},
+	{4.08, 0.85},
+	{4.11, 0.90},
+	{4.15, 0.95},
+	{4.20, 1},
+}
+
+func lipoStateOfCharge(cellVolts float64) float64 {
+	if cellVolts <= lipoCurve[0].volts {
+		return 0
+	}
+	for i := 1; i < len(lipoCurve); i++ {
+		lo, hi := lipoCurve[i-1], lipoCurve[i]
+		if cellVolts < hi.volts {
+			return lo.soc + (hi.soc-lo.soc)*(cellVolts-lo.volts)/(hi.volts-lo.volts)
+		}
+	}
+	return 1
+}
+
+// loopAlertingBattery shows a screen notice for the battery level and plays a sound each time it
+// gets worse.
+func (h *Hardware) loopAlertingBattery(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	last := BatteryOK
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		s := h.BatteryStatus()
+		if s.Level == last {
+			continue
+		}
+		for l, notice := range batteryNotices {
+			if l == s.Level {
+				screen.SetNotice(notice, screen.LevelErr)
+			} else {
+				screen.ClearNotice(notice)
+			}
+		}
+		for _, b := range s.Buses {
+			fmt.Printf("Battery: %s bus %.2fV/cell (%.0f%%) level %v\n",
+				b.Name, b.RestingCellVolts, b.StateOfCharge*100, b.Level)
+		}
+		if s.Level > last {
+			fmt.Println("Battery: level now", s.Level)
+			h.PlaySound(batterySounds[s.Level])
+		} else {
+			fmt.Println("Battery: recovered to", s.Level)
+		}
+		last = s.Level
+	}
+}
@@ -0,0 +1,83 @@
+package hardware
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func feed(b *batteryMonitor, bus BatteryBus, volts, amps float64, n int) {
+	for i := 0; i < n; i++ {
+		b.record(bus, PowerReading{Time: time.Now(), Volts: volts, Amps: amps})
+	}
+}
+
+func TestBatteryPolicyEscalates(t *testing.T) {
+	b := newBatteryMonitor(DefaultBatteryConfig(), []string{"Pi", "Traction"}, []int{4, 4})
+
+	feed(b, 1, 16.8, 0, 5)
+	if s := b.Status(); s.Level != BatteryOK || s.Buses[1].StateOfCharge != 1 {
+		t.Fatalf("Expected a full battery, got %+v", s.Buses[1])
+	}
+
+	// A single sag under load shouldn't do anything.
+	feed(b, 1, 12.8, 4, 1)
+	if l := b.Status().Level; l != BatteryOK {
+		t.Fatalf("Reacted to a single reading: %v", l)
+	}
+
+	for _, tc := range []struct {
+		cellVolts float64
+		expected  BatteryLevel
+	}{
+		{3.55, BatteryLow},
+		{3.45, BatteryThrottleCapped},
+		{3.35, BatteryNoAutonomous},
+		{3.25, BatteryFlat},
+		// Bouncing back when the motors stop isn't enough to leave a level...
+		{3.4, BatteryFlat},
+		// ...but a fresh battery is.
+		{4.1, BatteryOK},
+	} {
+		feed(b, 1, tc.cellVolts*4, 0, batteryFilterLen)
+		if s := b.Status(); s.Level != tc.expected {
+			t.Errorf("At %.2fV/cell expected %v, got %v", tc.cellVolts, tc.expected, s.Level)
+		}
+	}
+}
+
+func TestBatteryCompensatesForLoad(t *testing.T) {
+	b := newBatteryMonitor(DefaultBatteryConfig(), []string{"Traction"}, []int{4})
+	// 3.55V/cell under a 6A load is 3.625V/cell at rest, above the low threshold.
+	feed(b, 0, 14.2, 6, batteryFilterLen)
+	if s := b.Status(); s.Level != BatteryOK || math.Abs(s.Buses[0].RestingCellVolts-3.625) > 0.001 {
+		t.Fatalf("Expected load to be accounted for, got %+v", s.Buses[0])
+	}
+}
+
+func TestBatteryDisconnected(t *testing.T) {
+	b := newBatteryMonitor(DefaultBatteryConfig(), []string{"Traction"}, []int{4})
+	feed(b, 0, 0.1, 0, batteryFilterLen)
+	if s := b.Status(); s.Level != BatteryOK || s.Buses[0].Connected {
+		t.Fatalf("Unpowered bus should be ignored, got %+v", s.Buses[0])
+	}
+}
+
+func TestBatteryLimitSpeeds(t *testing.T) {
+	b := newBatteryMonitor(DefaultBatteryConfig(), []string{"Traction"}, []int{4})
+	feed(b, 0, 16, 0, batteryFilterLen)
+	if fl, fr, bl, br := b.limitSpeeds(4096, -4096, 2048, 0); fl != 4096 || fr != -4096 || bl != 2048 || br != 0 {
+		t.Fatalf("Speeds limited with a good battery: %v %v %v %v", fl, fr, bl, br)
+	}
+
+	feed(b, 0, 3.45*4, 0, batteryFilterLen)
+	// Capped to 1.5 RPS, keeping the ratios.
+	if fl, fr, bl, br := b.limitSpeeds(4096, -4096, 2048, 0); fl != 1536 || fr != -1536 || bl != 768 || br != 0 {
+		t.Fatalf("Speeds not capped: %v %v %v %v", fl, fr, bl, br)
+	}
+
+	feed(b, 0, 3.2*4, 0, batteryFilterLen)
+	if fl, fr, bl, br := b.limitSpeeds(4096, -4096, 2048, 0); fl != 0 || fr != 0 || bl != 0 || br != 0 {
+		t.Fatalf("Motors not stopped: %v %v %v %v", fl, fr, bl, br)
+	}
+}
@@ -0,0 +1,97 @@
+package hardware
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/tunable"
+)
+
+const (
+	headingGainsConfigFile      = "/cfg/heading-gains.yaml"
+	headingGainsConfigInUseFile = "/cfg/heading-gains-in-use.yaml"
+)
+
+// HeadingGainsConfig holds the heading PID gains for holding a heading (Absolute) and for driving by
+// yaw rate (Relative).
+type HeadingGainsConfig struct {
+	Absolute headingholder.Gains
+	Relative headingholder.Gains
+}
+
+func DefaultHeadingGainsConfig() HeadingGainsConfig {
+	return HeadingGainsConfig{
+		Absolute: headingholder.DefaultAbsoluteGains(),
+		Relative: headingholder.DefaultRelativeGains(),
+	}
+}
+
+func loadHeadingGainsConfig() HeadingGainsConfig {
+	config := DefaultHeadingGainsConfig()
+	cfg, err := ioutil.ReadFile(headingGainsConfigFile)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		err = yaml.Unmarshal(cfg, &config)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	fmt.Printf("HH: Using config: %#v\n", config)
+	writeHeadingGainsInUse(config)
+	return config
+}
+
+// writeHeadingGainsInUse writes out the config that we are using.
+func writeHeadingGainsInUse(config HeadingGainsConfig) {
+	cfgBytes, err := yaml.Marshal(&config)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	err = ioutil.WriteFile(headingGainsConfigInUseFile, cfgBytes, 0666)
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+// HeadingGainSet picks one of the sets of gains in HeadingGainsConfig.
+type HeadingGainSet int
+
+const (
+	AbsoluteGains HeadingGainSet = iota
+	RelativeGains
+)
+
+// AddHeadingGainTunables adds tunables for one set of heading gains, so that they can be adjusted with
+// the D-pad.  The tunables are integers so the smaller gains are scaled up.
+func AddHeadingGainTunables(t *tunable.Tunables, hw Interface, set HeadingGainSet) {
+	gainsIn := func(config *HeadingGainsConfig) *headingholder.Gains {
+		if set == RelativeGains {
+			return &config.Relative
+		}
+		return &config.Absolute
+	}
+	prefix := "Heading"
+	if set == RelativeGains {
+		prefix = "Yaw rate"
+	}
+	config := hw.HeadingGains()
+	gains := *gainsIn(&config)
+	add := func(name string, value, scale float64, setGain func(g *headingholder.Gains, v float64)) {
+		t.Create(prefix+" "+name, int(math.Round(value*scale))).OnChange = func(newValue int) {
+			config := hw.HeadingGains()
+			setGain(gainsIn(&config), float64(newValue)/scale)
+			hw.SetHeadingGains(config)
+		}
+	}
+	add("Kp x10", gains.Kp, 10, func(g *headingholder.Gains, v float64) { g.Kp = v })
+	add("Ki x100", gains.Ki, 100, func(g *headingholder.Gains, v float64) { g.Ki = v })
+	add("Kd x100", gains.Kd, 100, func(g *headingholder.Gains, v float64) { g.Kd = v })
+	add("max I", gains.MaxIntegral, 1, func(g *headingholder.Gains, v float64) { g.MaxIntegral = v })
+	add("max D", gains.MaxD, 1, func(g *headingholder.Gains, v float64) { g.MaxD = v })
+}
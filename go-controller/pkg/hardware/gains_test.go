@@ -0,0 +1,49 @@
+package hardware
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/tunable"
+)
+
+func TestHeadingGainTunables(t *testing.T) {
+	hw := &Hardware{gains: DefaultHeadingGainsConfig()}
+	var tunables tunable.Tunables
+	AddHeadingGainTunables(&tunables, hw, AbsoluteGains)
+
+	for _, tu := range tunables.All {
+		if tu.Name == "Heading Kd x100" {
+			if tu.Get() != 20 {
+				t.Fatalf("Expected the default Kd to be scaled to 20, got %d", tu.Get())
+			}
+			tu.Add(5)
+		}
+	}
+
+	gains := hw.HeadingGains()
+	expected := DefaultHeadingGainsConfig()
+	expected.Absolute.Kd = 0.25
+	if gains != expected {
+		t.Fatalf("Unexpected gains after tuning Kd: %+v", gains)
+	}
+}
+
+func TestRelativeHeadingGainTunables(t *testing.T) {
+	hw := &Hardware{gains: DefaultHeadingGainsConfig()}
+	var tunables tunable.Tunables
+	AddHeadingGainTunables(&tunables, hw, RelativeGains)
+
+	for _, tu := range tunables.All {
+		if tu.Name == "Yaw rate Kp x10" {
+			tu.Add(1)
+		}
+	}
+
+	gains := hw.HeadingGains()
+	expected := DefaultHeadingGainsConfig()
+	expected.Relative.Kp += 0.1
+	if math.Abs(gains.Relative.Kp-expected.Relative.Kp) > 1e-9 || gains.Absolute != expected.Absolute {
+		t.Fatalf("Unexpected gains after tuning the yaw rate Kp: %+v", gains)
+	}
+}
@@ -3,9 +3,12 @@ package hardware
 import (
 	"context"
 	"fmt"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/bno08x"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder/angle"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/kinematics"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder"
@@ -14,22 +17,36 @@ import (
 )
 
 type Hardware struct {
-	i2c I2CInterface
+	i2c  I2CInterface
+	imu  *headingService
+	slip *slipMonitor
 
 	soundsToPlay chan string
 
 	cancelCurrentControlMode context.CancelFunc
 	currentControlModeDone   sync.WaitGroup
-	imu                      interface {
-		CurrentHeading() angle.PlusMinus180
-	}
+	// controller is the motion controller, if motor control is on.  It keeps running from one
+	// heading hold or yaw-rate mode to the next, until StopMotorControl.
+	controller atomic.Pointer[headingholder.Controller]
+
+	limitsConfig MotionLimitsConfig
+
+	gainsLock sync.Mutex
+	gains     HeadingGainsConfig
+	// yawRateGains is true if the controller is using the relative gains, because it was last started
+	// in yaw-rate mode.
+	yawRateGains bool
 }
 
 func New() *Hardware {
 	i2c := NewI2CController()
 	return &Hardware{
 		i2c:          i2c,
+		imu:          newHeadingService(bno08x.New()),
+		slip:         newSlipMonitor(loadSlipConfig()),
 		soundsToPlay: sound.InitSound(),
+		limitsConfig: loadMotionLimitsConfig(),
+		gains:        loadHeadingGainsConfig(),
 	}
 }
 
@@ -38,6 +55,9 @@ var _ Interface = (*Hardware)(nil)
 func (h *Hardware) Start(ctx context.Context) {
 	var initDone sync.WaitGroup
 	go screen.LoopUpdatingScreen(ctx)
+	go h.imu.loop(ctx)
+	go h.slip.loop(ctx, h.imu, h.i2c)
+	go h.loopAlertingBattery(ctx)
 	initDone.Add(1)
 	go h.i2c.Loop(ctx, &initDone)
 	initDone.Wait()
@@ -49,30 +69,66 @@ func (h *Hardware) StartRawControlMode() RawControl {
 	return h.i2c
 }
 
+// StartHeadingHoldMode switches the controller to holding the heading that it was aiming for, starting
+// it if need be.  If it was already running, in either mode, the bot carries on without stopping.
 func (h *Hardware) StartHeadingHoldMode() HeadingAbsolute {
-	h.StopMotorControl()
-
-	var ctx context.Context
-	ctx, h.cancelCurrentControlMode = context.WithCancel(context.Background())
-
-	hh := headingholder.NewAbsolute(h.i2c)
-	h.currentControlModeDone.Add(1)
-	go hh.Loop(ctx, &h.currentControlModeDone)
-	h.imu = hh
+	hh, running := h.motionController()
+	h.applyGains(hh, false)
+	h.applyLimits(hh, headingholder.CarefulLimits())
+	if running {
+		hh.HoldHeading()
+		return hh
+	}
+	// Heading 0 is no longer wherever we happen to be facing so hold the current heading until told
+	// otherwise.
+	hh.SetHeading(h.CurrentHeading().Float())
+	h.startController(hh)
 	return hh
 }
 
+// StartYawAndThrottleMode applies the yaw-rate driving gains and limits, starting the controller if
+// need be.  The controller switches to yaw-rate control at the first SetYawAndThrottle, carrying on
+// from the heading that it was aiming for.
 func (h *Hardware) StartYawAndThrottleMode() HeadingRelative {
-	h.StopMotorControl()
+	hh, running := h.motionController()
+	h.applyGains(hh, true)
+	h.applyLimits(hh, headingholder.RCLimits())
+	if !running {
+		h.startController(hh)
+	}
+	return hh
+}
 
+// motionController returns the running controller, if there is one, or a new one that hasn't been
+// started yet.
+func (h *Hardware) motionController() (hh *headingholder.Controller, running bool) {
+	if hh := h.controller.Load(); hh != nil {
+		return hh, true
+	}
+	hh = headingholder.NewController(h.i2c)
+	hh.IMU = h.imu
+	hh.Reference = h.imu
+	return hh, false
+}
+
+func (h *Hardware) startController(hh *headingholder.Controller) {
 	var ctx context.Context
 	ctx, h.cancelCurrentControlMode = context.WithCancel(context.Background())
-
-	hh := headingholder.NewYawRateAndThrottle(h.i2c)
 	h.currentControlModeDone.Add(1)
 	go hh.Loop(ctx, &h.currentControlModeDone)
-	h.imu = hh
-	return hh
+	h.controller.Store(hh)
+}
+
+// applyGains gives the controller the gains for driving by yaw rate or for holding a heading.
+func (h *Hardware) applyGains(hh *headingholder.Controller, yawRate bool) {
+	h.gainsLock.Lock()
+	h.yawRateGains = yawRate
+	gains := h.gains.Absolute
+	if yawRate {
+		gains = h.gains.Relative
+	}
+	h.gainsLock.Unlock()
+	hh.SetGains(gains)
 }
 
 func (h *Hardware) StopMotorControl() {
@@ -83,27 +139,126 @@ func (h *Hardware) StopMotorControl() {
 		h.cancelCurrentControlMode = nil
 		fmt.Println("HW: Stopped motor control")
 	}
-	h.imu = nil
+	h.controller.Store(nil)
+	h.i2c.SetCurrentCeiling(0)
+	h.i2c.SetWheelSpeedLimit(0)
 	h.i2c.SetMotorSpeeds(0, 0, 0, 0)
 	time.Sleep(30 * time.Millisecond)
 }
 
-func (h *Hardware) CurrentHeading() angle.PlusMinus180 {
-	if h.imu == nil {
-		return angle.PlusMinus180{}
+func (h *Hardware) UseMotionLimits(preset string) {
+	limits, ok := h.limitsConfig.Presets[preset]
+	if !ok {
+		fmt.Printf("HW: Unknown motion limits preset %q, using %q\n", preset, LimitsChallenge)
+		preset = LimitsChallenge
+		limits = h.limitsConfig.Presets[preset]
 	}
-	// FIXME Run IMU all the time, not just when heading holding.
+	fmt.Printf("HW: Using %s motion limits: %+v\n", preset, limits)
+	h.SetMotionLimits(limits)
+}
+
+func (h *Hardware) SetMotionLimits(limits headingholder.MotionLimits) {
+	h.applyLimits(h.controller.Load(), limits)
+}
+
+// applyLimits gives the controller (if there is one) the limits and passes them on to the I2C loop,
+// which enforces the current ceiling and starts any thermal derating from the wheel speed limit, so
+// that nothing is left over from the previous limits.
+func (h *Hardware) applyLimits(hh *headingholder.Controller, limits headingholder.MotionLimits) {
+	if hh != nil {
+		hh.SetLimits(limits)
+	}
+	h.i2c.SetCurrentCeiling(limits.MaxBatteryAmps)
+	h.i2c.SetWheelSpeedLimit(limits.MaxWheelRPS)
+}
+
+// MotionLimits returns the limits of the controller, or zero if motor control is off.
+func (h *Hardware) MotionLimits() headingholder.MotionLimits {
+	if hh := h.controller.Load(); hh != nil {
+		return hh.Limits()
+	}
+	return headingholder.MotionLimits{}
+}
+
+func (h *Hardware) HeadingGains() HeadingGainsConfig {
+	h.gainsLock.Lock()
+	defer h.gainsLock.Unlock()
+	return h.gains
+}
+
+// SetHeadingGains applies the gains to the controller, if it's running, and whenever a control mode
+// starts.  The new gains are written to the in-use file so that good ones can be copied into the
+// config.
+func (h *Hardware) SetHeadingGains(gains HeadingGainsConfig) {
+	h.gainsLock.Lock()
+	h.gains = gains
+	active := gains.Absolute
+	if h.yawRateGains {
+		active = gains.Relative
+	}
+	h.gainsLock.Unlock()
+
+	if hh := h.controller.Load(); hh != nil {
+		hh.SetGains(active)
+	}
+	fmt.Printf("HW: Heading gains now absolute: %v; relative: %v\n", gains.Absolute, gains.Relative)
+	writeHeadingGainsInUse(gains)
+}
+
+func (h *Hardware) CurrentHeading() angle.PlusMinus180 {
 	return h.imu.CurrentHeading()
 }
 
+// TargetHeading returns the heading that the controller is aiming for; ok is false unless it's holding
+// a heading.
+func (h *Hardware) TargetHeading() (target angle.PlusMinus180, ok bool) {
+	hh := h.controller.Load()
+	if hh == nil || hh.Mode() != headingholder.HeadingMode {
+		return angle.PlusMinus180{}, false
+	}
+	return hh.TargetHeading(), true
+}
+
 func (h *Hardware) CurrentDistanceReadings(rev revision) DistanceReadings {
 	return h.i2c.CurrentDistanceReadings(rev)
 }
 
+func (h *Hardware) WaitForDistanceReadings(ctx context.Context, rev revision) (DistanceReadings, error) {
+	return h.i2c.WaitForDistanceReadings(ctx, rev)
+}
+
 func (h *Hardware) AccumulatedRotations() picobldc.PerMotorVal[float64] {
 	return h.i2c.AccumulatedRotations()
 }
 
+func (h *Hardware) WheelVelocities() picobldc.WheelVelocities {
+	return h.i2c.WheelVelocities()
+}
+
+func (h *Hardware) PicoFaults() []PicoFault {
+	return h.i2c.PicoFaults()
+}
+
+func (h *Hardware) SubscribePicoFaults(ctx context.Context) <-chan PicoFault {
+	return h.i2c.SubscribePicoFaults(ctx)
+}
+
+func (h *Hardware) ClearPicoLockout() {
+	h.i2c.ClearPicoLockout()
+}
+
+func (h *Hardware) ThermalStatus() ThermalStatus {
+	return h.i2c.ThermalStatus()
+}
+
+func (h *Hardware) BatteryStatus() BatteryStatus {
+	return h.i2c.BatteryStatus()
+}
+
+func (h *Hardware) WheelSlipSince(t time.Time) kinematics.SlipStatus {
+	return h.slip.WorstSince(t)
+}
+
 func (h *Hardware) DisableServos() {
 	for i := 0; i < 16; i++ {
 		h.i2c.SetPWM(i, 0)
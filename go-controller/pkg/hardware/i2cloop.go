@@ -2,10 +2,12 @@ package hardware
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/i2cbus"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/pca9685"
 
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/screen"
@@ -13,6 +15,7 @@ import (
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/ina219"
 
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/telemetry"
 )
 
 const (
@@ -24,9 +27,16 @@ const (
 	NotePowerMon = "PWR MON"
 
 	motorToMMScaleFactor = 7.384
+
+	powerReadingInterval = time.Second
+	powerPrintInterval   = 5 * time.Second
+	i2cRecoveryDelay     = 100 * time.Millisecond
 )
 
 type I2CController struct {
+	// bus is the main I2C bus, with the Pico, power monitor and servo controller on it.
+	bus i2cbus.Bus
+
 	lock sync.Mutex
 
 	// Desired values.  Stored off in case we need to re-initialise the hardware.
@@ -36,12 +46,17 @@ type I2CController struct {
 
 	prop        picobldc.Interface
 	tofsEnabled bool
+	battery     *batteryMonitor
+	thermal     *thermalMonitor
+	current     *currentLimiter
+	picoFaults  picoFaultLog
 
 	revisionUpdated               *sync.Cond
 	nextRevision                  revision
 	distanceReadings              DistanceReadings
 	leftMotorDist, rightMotorDist float64
 	accumulatedRotations          picobldc.PerMotorVal[float64]
+	wheelVelocities               picobldc.WheelVelocities
 }
 
 type pwmTypes interface {
@@ -57,11 +72,21 @@ type pwmValue float64
 func (pwmValue) pwmsOnly() {}
 
 func NewI2CController() *I2CController {
+	return NewI2CControllerOnBus(i2cbus.Devfs("/dev/i2c-1"))
+}
+
+func NewI2CControllerOnBus(bus i2cbus.Bus) *I2CController {
 	c := &I2CController{
+		bus: bus,
+
 		pwmPorts:            map[int]pwmTypes{},
 		pwmPortsWithUpdates: map[int]bool{},
 
 		tofsEnabled: true,
+		// The Pi's power monitor is on the main bus, the Pico has one for the traction battery.
+		battery: newBatteryMonitor(loadBatteryConfig(), []string{"Pi", "Traction"}, []int{4, 4}),
+		thermal: newThermalMonitor(loadThermalConfig()),
+		current: newCurrentLimiter(),
 
 		nextRevision: 1,
 	}
@@ -82,6 +107,7 @@ func (c *I2CController) SetMotorSpeeds(frontLeft, frontRight, backLeft, backRigh
 	c.motorFL = frontLeft
 	c.motorBL = backLeft
 	c.motorBR = backRight
+	telemetry.RecordMotorSpeeds(frontLeft, frontRight, backLeft, backRight)
 	return nil
 }
 
@@ -110,6 +136,35 @@ func (c *I2CController) CurrentDistanceReadings(rev revision) DistanceReadings {
 
 	return c.distanceReadings
 }
+func (c *I2CController) SetCurrentCeiling(amps float64) {
+	c.current.setCeiling(amps)
+}
+
+func (c *I2CController) SetWheelSpeedLimit(rps float64) {
+	c.thermal.setWheelLimit(rps)
+}
+
+func (c *I2CController) BatteryStatus() BatteryStatus {
+	return c.battery.Status()
+}
+
+// PicoFaults returns the faults that the loop has seen on the Pico, oldest first.
+func (c *I2CController) PicoFaults() []PicoFault {
+	return c.picoFaults.History()
+}
+
+func (c *I2CController) SubscribePicoFaults(ctx context.Context) <-chan PicoFault {
+	return c.picoFaults.Subscribe(ctx)
+}
+
+func (c *I2CController) ClearPicoLockout() {
+	c.picoFaults.ClearLockout()
+}
+
+func (c *I2CController) ThermalStatus() ThermalStatus {
+	return c.thermal.Status()
+}
+
 func (c *I2CController) AccumulatedRotations() picobldc.PerMotorVal[float64] {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -117,30 +172,50 @@ func (c *I2CController) AccumulatedRotations() picobldc.PerMotorVal[float64] {
 	return c.accumulatedRotations
 }
 
+func (c *I2CController) WheelVelocities() picobldc.WheelVelocities {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.wheelVelocities
+}
+
 func (c *I2CController) Loop(ctx context.Context, initDone *sync.WaitGroup) {
 	fmt.Println("I2C loop started")
+	go c.tofLoop(ctx)
 	for {
-		c.loopUntilSomethingBadHappens(ctx, initDone)
+		err := c.loopUntilSomethingBadHappens(ctx, initDone)
 		if ctx.Err() != nil {
 			return
 		}
-		fmt.Println("===== !!! WARNING !!! I2C FAILURE; TRYING TO RECOVER =====")
+		fmt.Println("===== !!! WARNING !!! I2C FAILURE; TRYING TO RECOVER =====", err)
 		initDone = nil
+
+		// If the Pico has gone away or the bus is stuck, retrying straight away won't help.  The
+		// Pico's watchdog stops the motors in the meantime.
+		if errors.Is(err, picobldc.ErrDeviceMissing) || errors.Is(err, picobldc.ErrBusStuck) {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(i2cRecoveryDelay):
+			}
+		}
 	}
 }
 
-func (c *I2CController) loopUntilSomethingBadHappens(ctx context.Context, initDone *sync.WaitGroup) {
+// loopUntilSomethingBadHappens returns the error that stopped it, or nil if the context was
+// cancelled.
+func (c *I2CController) loopUntilSomethingBadHappens(ctx context.Context, initDone *sync.WaitGroup) error {
 	defer func() {
 		if initDone != nil {
 			initDone.Done()
 		}
 	}()
 
-	pico, err := picobldc.New()
+	pico, err := picobldc.NewOnBus(c.bus)
 	if err != nil {
 		fmt.Println("Failed to open Pico", err)
 		screen.SetNotice(NotePico, screen.LevelErr)
-		return
+		return err
 	}
 	defer func() {
 		_ = pico.Close()
@@ -149,10 +224,9 @@ func (c *I2CController) loopUntilSomethingBadHappens(ctx context.Context, initDo
 	distanceTracker := picobldc.NewDistanceTracker(pico)
 
 	// Only one sensor on the main bus, Pico also has one as a peripheral.
-	var powerSensors []powerMonitor
-	var busCells = []int{4, 4}
+	var powerSensors []powerSensor
 	for _, addr := range []int{ina219.Addr1} {
-		pwrSen, err := ina219.NewI2C("/dev/i2c-1", addr)
+		pwrSen, err := ina219.NewOnBus(c.bus, addr)
 		if err != nil {
 			fmt.Println("Failed to open power sensor; ignoring! ", err)
 			continue
@@ -164,9 +238,9 @@ func (c *I2CController) loopUntilSomethingBadHappens(ctx context.Context, initDo
 			fmt.Println("Failed to open power sensor; ignoring! ", err)
 			continue
 		}
-		powerSensors = append(powerSensors, pwrSen)
+		powerSensors = append(powerSensors, powerSensor{bus: BatteryBusPi, mon: pwrSen})
 	}
-	powerSensors = append(powerSensors, pico)
+	powerSensors = append(powerSensors, powerSensor{bus: BatteryBusTraction, mon: pico})
 
 	dummyServos := pca9685.Dummy()
 	var servos = dummyServos
@@ -184,7 +258,7 @@ func (c *I2CController) loopUntilSomethingBadHappens(ctx context.Context, initDo
 			_ = servos.Close()
 			servos = dummyServos
 		}
-		servos, err = pca9685.New("/dev/i2c-1")
+		servos, err = pca9685.NewOnBus(c.bus)
 		if err != nil {
 			fmt.Println("Failed open PCA9685 ", err)
 			screen.SetNotice(NoteServo, screen.LevelErr)
@@ -214,15 +288,17 @@ func (c *I2CController) loopUntilSomethingBadHappens(ctx context.Context, initDo
 	ticker := time.NewTicker(25 * time.Millisecond)
 
 	var lastFL, lastFR, lastBL, lastBR int16
-	var lastPowerReadingTime time.Time
+	var lastPowerReadingTime, lastPowerPrintTime time.Time
 	var lastMotorUpdTime time.Time
+	var lastStatusPollTime time.Time
+	var lastCurrentReadingTime time.Time
 
 	// Enable Pico watchdog just before we start the loop.
 	const picoWatchdogTimeout = time.Second
 	if err := pico.SetWatchdog(picoWatchdogTimeout); err != nil {
 		fmt.Println("Failed to configure Pico watchdog", err)
 		screen.SetNotice(NotePico, screen.LevelErr)
-		return
+		return err
 	}
 	fmt.Println("Pico watchdog enabled.")
 
@@ -237,6 +313,39 @@ func (c *I2CController) loopUntilSomethingBadHappens(ctx context.Context, initDo
 		c.lock.Lock()
 		fl, fr, bl, br := c.motorFL, c.motorFR, c.motorBL, c.motorBR
 		c.lock.Unlock()
+		fl, fr, bl, br = c.battery.limitSpeeds(fl, fr, bl, br)
+		fl, fr, bl, br = c.thermal.limitSpeeds(fl, fr, bl, br)
+		fl, fr, bl, br = c.current.limitSpeeds(fl, fr, bl, br)
+		if c.picoFaults.LockedOut() {
+			// Don't restart the motors unexpectedly when the lockout is cleared.
+			fl, fr, bl, br = 0, 0, 0, 0
+		}
+
+		if time.Since(lastStatusPollTime) > picoStatusPollInterval {
+			lastStatusPollTime = time.Now()
+			fault, err := c.picoFaults.checkPico(pico)
+			if err != nil {
+				fmt.Println("Failed to recover Pico", err)
+				screen.SetNotice(NotePico, screen.LevelErr)
+				return err
+			}
+			if fault != nil {
+				// The Pico has stopped the motors; force an update.
+				lastMotorUpdTime = time.Time{}
+				if fault.Kind == PicoRebooted {
+					distanceTracker.Resync()
+				}
+			}
+		}
+
+		if c.current.active() && time.Since(lastCurrentReadingTime) > currentLimitInterval {
+			lastCurrentReadingTime = time.Now()
+			if amps, err := pico.CurrentAmps(); err != nil {
+				fmt.Println("Failed to read traction current", err)
+			} else {
+				c.current.record(lastCurrentReadingTime, amps)
+			}
+		}
 
 		speedsChanged := fl != lastFL || fr != lastFR || bl != lastBL || br != lastBR
 		needToPetWatchdog := time.Since(lastMotorUpdTime) > (picoWatchdogTimeout / 10)
@@ -245,9 +354,10 @@ func (c *I2CController) loopUntilSomethingBadHappens(ctx context.Context, initDo
 			if err != nil {
 				fmt.Println("Failed to update motor speeds", err)
 				screen.SetNotice(NotePico, screen.LevelErr)
-				return
+				return err
 			}
 			lastFL, lastFR, lastBL, lastBR = fl, fr, bl, br
+			distanceTracker.SetCommandedSpeeds(fl, fr, bl, br)
 			screen.ClearNotice(NotePico)
 			lastMotorUpdTime = time.Now()
 		}
@@ -257,8 +367,11 @@ func (c *I2CController) loopUntilSomethingBadHappens(ctx context.Context, initDo
 			screen.SetNotice(NotePico, screen.LevelErr)
 		} else {
 			acc := distanceTracker.AccumulatedRotations()
+			vel := distanceTracker.Velocities()
+			telemetry.RecordRotations(acc)
 			c.lock.Lock()
 			c.accumulatedRotations = acc
+			c.wheelVelocities = vel
 			c.lock.Unlock()
 		}
 
@@ -292,35 +405,47 @@ func (c *I2CController) loopUntilSomethingBadHappens(ctx context.Context, initDo
 			}
 		}
 
-		if time.Since(lastPowerReadingTime) > 5*time.Second {
-			for i, ps := range powerSensors {
-				bv, err := ps.BusVoltage()
+		if time.Since(lastPowerReadingTime) > powerReadingInterval {
+			printPower := time.Since(lastPowerPrintTime) > powerPrintInterval
+			if tempC, err := pico.TemperatureC(); err != nil {
+				fmt.Println("Failed to read Pico temperature", err)
+			} else {
+				c.thermal.record(time.Now(), tempC)
+			}
+			for _, ps := range powerSensors {
+				bv, err := ps.mon.BusVoltage()
 				if err != nil {
 					screen.SetNotice(NotePowerMon, screen.LevelErr)
 					continue
 				}
-				bc, err := ps.CurrentAmps()
+				bc, err := ps.mon.CurrentAmps()
 				if err != nil {
 					screen.SetNotice(NotePowerMon, screen.LevelErr)
 					continue
 				}
-				bp, err := ps.PowerWatts()
+				bp, err := ps.mon.PowerWatts()
 				if err != nil {
 					screen.SetNotice(NotePowerMon, screen.LevelErr)
 					continue
 				}
-				name := "Pi"
-				if i > 0 {
-					name = "Traction"
+				now := time.Now()
+				c.battery.record(ps.bus, PowerReading{Time: now, Volts: bv, Amps: bc, Watts: bp})
+				bs := c.battery.Status().Buses[ps.bus]
+				if printPower {
+					fmt.Printf("%v bus: %.2fV %.2fA %.2fW %.0f%% ", bs.Name, bv, bc, bp, bs.StateOfCharge*100)
 				}
-				fmt.Printf("%v bus: %.2fV %.2fA %.2fW ", name, bv, bc, bp)
+				telemetry.RecordPower(int(ps.bus), bv, bc, bp)
 				screen.ClearNotice(NotePowerMon)
-				screen.SetBusVoltage(i, bv, busCells[i])
+				screen.SetBusVoltage(int(ps.bus), bv, bs.Cells)
+			}
+			if printPower {
+				fmt.Println()
+				lastPowerPrintTime = time.Now()
 			}
-			fmt.Println()
 			lastPowerReadingTime = time.Now()
 		}
 	}
+	return nil
 }
 
 type powerMonitor interface {
@@ -328,3 +453,10 @@ type powerMonitor interface {
 	CurrentAmps() (float64, error)
 	PowerWatts() (float64, error)
 }
+
+// powerSensor is a power monitor along with the bus that it measures; the Pi's monitor may be
+// missing so we can't go by position.
+type powerSensor struct {
+	bus BatteryBus
+	mon powerMonitor
+}
@@ -0,0 +1,230 @@
+package hardware
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/i2cbus"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/ina219"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/pca9685"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
+)
+
+func startEmulatedI2CLoop(t *testing.T) (*I2CController, *picobldc.Emulator, *i2cbus.Fake) {
+	bus := i2cbus.NewFake()
+	emu := picobldc.NewEmulator()
+	bus.Attach(picobldc.PicoAddr, emu)
+	bus.Attach(ina219.Addr1, i2cbus.NewRegisters(2))
+	bus.Attach(pca9685.DefaultAddr, i2cbus.NewRegisters(1))
+	return startI2CLoopOnBus(t, bus), emu, bus
+}
+
+func startI2CLoopOnBus(t *testing.T, bus i2cbus.Bus) *I2CController {
+	c := NewI2CControllerOnBus(bus)
+	c.SetToFsEnabled(false)
+	ctx, cancel := context.WithCancel(context.Background())
+	var initDone sync.WaitGroup
+	initDone.Add(1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.Loop(ctx, &initDone)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	initDone.Wait()
+	return c
+}
+
+func TestI2CLoopPetsWatchdog(t *testing.T) {
+	c, emu, bus := startEmulatedI2CLoop(t)
+
+	if err := c.SetMotorSpeeds(picobldc.RPSToMotorSpeed(1), 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	// A few glitches on the bus shouldn't stop the motors.
+	time.Sleep(200 * time.Millisecond)
+	bus.InjectFaults(picobldc.PicoAddr, i2cbus.ErrTransient, i2cbus.ErrTransient)
+
+	// Run for longer than the watchdog timeout without changing the speeds.
+	time.Sleep(1500 * time.Millisecond)
+	if emu.Status()&picobldc.RegStatusWatchdogExpired != 0 {
+		t.Fatal("Watchdog expired while the loop was running")
+	}
+	if s := emu.RunningSpeeds(); s[picobldc.FrontLeft] != 1 {
+		t.Fatalf("Expected front left motor to be running, got %v", s)
+	}
+	rot := c.AccumulatedRotations()[picobldc.FrontLeft]
+	if rot < 1.4 || rot > 1.8 {
+		t.Fatalf("Expected about 1.7 rotations, got %.2f", rot)
+	}
+}
+
+func TestI2CLoopRecoversFromPicoFaults(t *testing.T) {
+	c, emu, _ := startEmulatedI2CLoop(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	faults := c.SubscribePicoFaults(ctx)
+
+	if err := c.SetMotorSpeeds(picobldc.RPSToMotorSpeed(1), 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	waitForFault := func(expected PicoFaultKind) {
+		t.Helper()
+		select {
+		case f := <-faults:
+			if f.Kind != expected || !f.Recovered {
+				t.Fatalf("Expected recovered %v, got %v", expected, f)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %v", expected)
+		}
+		// Give the loop a tick to restart the motors.
+		time.Sleep(100 * time.Millisecond)
+		if s := emu.RunningSpeeds(); s[picobldc.FrontLeft] != 1 {
+			t.Fatalf("Motors didn't restart after %v: %v", expected, s)
+		}
+	}
+
+	emu.InjectFault()
+	waitForFault(PicoMotorFault)
+	if n := emu.Register(picobldc.RegFaultCount); n != 1 {
+		t.Errorf("Fault count %d", n)
+	}
+
+	emu.Reboot()
+	waitForFault(PicoRebooted)
+	if timeout := emu.Register(picobldc.RegWatchdogTimeout); timeout != 1000 {
+		t.Errorf("Watchdog timeout not restored: %d", timeout)
+	}
+	if calib := emu.Register(picobldc.RegMot3Calib); calib == 0 {
+		t.Error("Calibration not restored")
+	}
+	// Running on should neither trip the watchdog nor count the reboot as a jump in the rotations.
+	time.Sleep(1200 * time.Millisecond)
+	if emu.Status()&picobldc.RegStatusWatchdogExpired != 0 {
+		t.Error("Watchdog expired after recovery")
+	}
+	if rot := c.AccumulatedRotations()[picobldc.FrontLeft]; rot < 0 || rot > 3 {
+		t.Errorf("Unexpected rotations after reboot: %.2f", rot)
+	}
+
+	history := c.PicoFaults()
+	if len(history) != 2 || history[0].Kind != PicoMotorFault || history[1].Kind != PicoRebooted {
+		t.Errorf("Unexpected fault history %v", history)
+	}
+}
+
+func TestI2CLoopSurvivesPicoDisappearing(t *testing.T) {
+	c, emu, bus := startEmulatedI2CLoop(t)
+
+	if err := c.SetMotorSpeeds(picobldc.RPSToMotorSpeed(1), 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// Unplug the Pico for a while; the loop should keep trying to reopen it rather than panicking.
+	bus.Detach(picobldc.PicoAddr)
+	time.Sleep(300 * time.Millisecond)
+	emu.Reboot()
+	bus.Attach(picobldc.PicoAddr, emu)
+
+	time.Sleep(300 * time.Millisecond)
+	if s := emu.RunningSpeeds(); s[picobldc.FrontLeft] != 1 {
+		t.Fatalf("Motors didn't restart when the Pico came back: %v", s)
+	}
+	if timeout := emu.Register(picobldc.RegWatchdogTimeout); timeout == 0 {
+		t.Error("Watchdog not re-armed")
+	}
+}
+
+func TestI2CLoopBacksOffRepeatedMotorFaults(t *testing.T) {
+	c, emu, _ := startEmulatedI2CLoop(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	faults := c.SubscribePicoFaults(ctx)
+
+	if err := c.SetMotorSpeeds(picobldc.RPSToMotorSpeed(1), 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	nextFault := func() PicoFault {
+		t.Helper()
+		select {
+		case f := <-faults:
+			return f
+		case <-time.After(3 * time.Second):
+			t.Fatal("Timed out waiting for a fault")
+		}
+		return PicoFault{}
+	}
+
+	// A motor that keeps faulting; the resets should get further apart and then stop.
+	var last PicoFault
+	for i := 1; i <= picoMaxMotorFaults; i++ {
+		emu.InjectFault()
+		f := nextFault()
+		if f.Kind != PicoMotorFault {
+			t.Fatalf("Expected a motor fault, got %v", f)
+		}
+		if recovered := i < picoMaxMotorFaults; f.Recovered != recovered {
+			t.Fatalf("Fault %d: expected recovered=%v, got %v", i, recovered, f)
+		}
+		if i > 1 {
+			if gap, backoff := f.Time.Sub(last.Time), picoResetBackoffMin<<(i-2); f.Recovered && gap < backoff {
+				t.Errorf("Fault %d reset after %v, expected to back off for %v", i, gap, backoff)
+			}
+		}
+		last = f
+	}
+	if !c.picoFaults.LockedOut() {
+		t.Fatal("Expected to be locked out")
+	}
+
+	// Locked out, the Pico is left faulted and the motors stopped.
+	select {
+	case f := <-faults:
+		t.Fatalf("Unexpected fault while locked out: %v", f)
+	case <-time.After(500 * time.Millisecond):
+	}
+	if emu.Status()&picobldc.RegStatusFault == 0 {
+		t.Fatal("Pico was reset while locked out")
+	}
+
+	// Once the operator clears the lockout, the Pico is reset and the motors start again.
+	c.ClearPicoLockout()
+	if f := nextFault(); f.Kind != PicoMotorFault || !f.Recovered {
+		t.Fatalf("Expected the Pico to be reset after clearing the lockout, got %v", f)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if s := emu.RunningSpeeds(); s[picobldc.FrontLeft] != 1 {
+		t.Fatalf("Motors didn't restart after clearing the lockout: %v", s)
+	}
+}
+
+func TestI2CLoopLabelsTractionBusWithoutPiMonitor(t *testing.T) {
+	// No INA219 for the Pi's battery; the Pico's readings still belong to the traction battery.
+	bus := i2cbus.NewFake()
+	emu := picobldc.NewEmulator()
+	bus.Attach(picobldc.PicoAddr, emu)
+	bus.Attach(pca9685.DefaultAddr, i2cbus.NewRegisters(1))
+	c := startI2CLoopOnBus(t, bus)
+
+	deadline := time.Now().Add(3 * time.Second)
+	for c.BatteryStatus().Buses[BatteryBusTraction].Latest.Time.IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for a traction battery reading")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	s := c.BatteryStatus()
+	if traction := s.Buses[BatteryBusTraction]; traction.Name != "Traction" || !traction.Connected {
+		t.Errorf("Expected the Pico's readings on the traction bus, got %+v", traction)
+	}
+	if pi := s.Buses[BatteryBusPi]; !pi.Latest.Time.IsZero() {
+		t.Errorf("Expected no readings for the Pi's battery, got %+v", pi)
+	}
+}
@@ -0,0 +1,75 @@
+package hardware
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/bno08x"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder/angle"
+)
+
+// headingService owns the IMU for the lifetime of the Hardware.  Heading 0 is wherever the bot was
+// facing when the first IMU report arrived; every motion controller shares that reference so
+// headings stay in the same frame across mode switches.
+type headingService struct {
+	bno08x.Interface
+
+	lock    sync.Mutex
+	zeroSet bool
+	zero    angle.PlusMinus180
+}
+
+var _ headingholder.HeadingReference = (*headingService)(nil)
+
+func newHeadingService(imu bno08x.Interface) *headingService {
+	return &headingService{Interface: imu}
+}
+
+// loop reads the IMU (if it needs driving) and records the heading reference from the first report.
+func (s *headingService) loop(ctx context.Context) {
+	if b, ok := s.Interface.(interface{ LoopReadingReports(context.Context) }); ok {
+		go b.LoopReadingReports(ctx)
+	}
+
+	lastPrint := time.Now()
+	for ctx.Err() == nil {
+		if r := s.CurrentReport(); !r.Time.IsZero() {
+			zero := s.ZeroYaw()
+			fmt.Printf("IMU: heading reference set, raw yaw %.2f\n", zero.Float())
+			return
+		}
+		if time.Since(lastPrint) > 5*time.Second {
+			fmt.Println("IMU: waiting for first report...")
+			lastPrint = time.Now()
+		}
+		time.Sleep(bno08x.ReportInterval)
+	}
+}
+
+// ZeroYaw returns the raw IMU yaw that counts as heading 0.  Must only be relied on once there has
+// been a report; until then it returns 0.
+func (s *headingService) ZeroYaw() angle.PlusMinus180 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.zeroSet {
+		r := s.CurrentReport()
+		if r.Time.IsZero() {
+			return angle.PlusMinus180{}
+		}
+		s.zero = r.RobotYaw()
+		s.zeroSet = true
+	}
+	return s.zero
+}
+
+func (s *headingService) CurrentHeading() angle.PlusMinus180 {
+	r := s.CurrentReport()
+	if r.Time.IsZero() {
+		return angle.PlusMinus180{}
+	}
+	return r.RobotYaw().Sub(s.ZeroYaw())
+}
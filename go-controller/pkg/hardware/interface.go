@@ -6,23 +6,65 @@ import (
 	"sync"
 	"time"
 
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder/angle"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/kinematics"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
 )
 
 type Interface interface {
 	Start(ctx context.Context)
 
-	// Enter a particular motor control mode (previous mode will be stopped if needed).
+	// Enter a particular motor control mode.  Raw control stops the motion controller; the heading
+	// hold and yaw-rate modes share it, so switching between them hands over mid-motion without
+	// stopping the bot or re-zeroing the heading.
 	StartRawControlMode() RawControl
 	StartHeadingHoldMode() HeadingAbsolute
 	StartYawAndThrottleMode() HeadingRelative
 	StopMotorControl()
 
+	// UseMotionLimits applies one of the motion limits presets (LimitsRC etc.) to the motion
+	// controller; SetMotionLimits applies custom limits.  Starting a control mode resets the limits to
+	// its defaults so call them after starting the mode.
+	UseMotionLimits(preset string)
+	SetMotionLimits(limits headingholder.MotionLimits)
+	MotionLimits() headingholder.MotionLimits
+
+	// HeadingGains returns the heading PID gains for each control mode; SetHeadingGains changes
+	// them, including for the running controller.
+	HeadingGains() HeadingGainsConfig
+	SetHeadingGains(gains HeadingGainsConfig)
+
 	// Read the current state of the hardware.  Reads the current best guess from cache.
 	CurrentHeading() angle.PlusMinus180
 	CurrentDistanceReadings(revision revision) DistanceReadings
+	// WaitForDistanceReadings waits for readings newer than the given revision.  Unlike
+	// CurrentDistanceReadings, it gives up if the context is cancelled or if the sensors stop producing
+	// readings, returning ErrDistanceReadingsStale in the latter case.
+	WaitForDistanceReadings(ctx context.Context, revision revision) (DistanceReadings, error)
 	AccumulatedRotations() picobldc.PerMotorVal[float64]
+	// WheelVelocities returns the measured speed of each wheel, filtered, along with the speed that
+	// it was last commanded to turn at.  Stalled flags wheels that have persistently failed to reach
+	// their commanded speed.
+	WheelVelocities() picobldc.WheelVelocities
+	// PicoFaults returns the faults that have been seen on the Pico-BLDC this run, oldest first.  The
+	// I2C loop recovers from them automatically, unless there are too many motor faults in a short
+	// time; then it leaves the motors stopped until ClearPicoLockout is called.
+	PicoFaults() []PicoFault
+	// SubscribePicoFaults returns a channel that receives each new Pico-BLDC fault until the context
+	// is cancelled.
+	SubscribePicoFaults(ctx context.Context) <-chan PicoFault
+	ClearPicoLockout()
+	// ThermalStatus returns the motor driver temperature and any speed limit that is in force to
+	// stop it overheating.
+	ThermalStatus() ThermalStatus
+	// BatteryStatus returns the recent power monitor readings and the undervoltage policy level that
+	// is in force.
+	BatteryStatus() BatteryStatus
+	// WheelSlipSince returns the worst wheel slip seen since the given time, according to the
+	// disagreement between the wheel odometry and the IMU.  Its Confidence says how far to trust the
+	// wheel rotations over that period.
+	WheelSlipSince(t time.Time) kinematics.SlipStatus
 
 	SetServo(port int, value float64)
 	SetPWM(port int, value float64)
@@ -36,9 +78,14 @@ type RawControl interface {
 
 type HeadingAbsolute interface {
 	SetHeading(desiredHeaading float64)
+	// SetHeadingWithProfile turns to the heading along a smooth profile, limited to maxRate
+	// (degrees/s) and maxAccel (degrees/s²), rather than as a step.
+	SetHeadingWithProfile(desiredHeading, maxRate, maxAccel float64)
 	AddHeadingDelta(delta float64)
 	SetThrottle(throttleMMPerS float64)
-	Wait(ctx context.Context) (residual float64, err error)
+	// WaitSettled waits for the heading to settle within toleranceDegrees of the target, for up to
+	// timeout, and says how it went.
+	WaitSettled(ctx context.Context, toleranceDegrees float64, settleTime, timeout time.Duration) headingholder.SettleResult
 
 	// SetThrottleWithAngle is like SetThrottle with an angled
 	//displacement - i.e. not changing the direction that the bot
@@ -54,6 +101,18 @@ type HeadingAbsolute interface {
 	// (So `SetThrottleWithAngle(throttle, 0)` should be
 	// equivalent to `SetThrottle(throttle)`.
 	SetThrottleWithAngle(throttleMMPerS float64, angle float64)
+
+	// MoveBy drives the bot by the given displacement, relative to the way it is facing, using the
+	// wheel rotations for feedback, and then stops.  It follows the trapezoidal velocity profile
+	// set with SetMoveProfile and returns the displacement that was achieved.
+	MoveBy(ctx context.Context, aheadMM, leftMM float64) (achievedAheadMM, achievedLeftMM float64, err error)
+	// MoveTo is like MoveBy but it drives to a point in the coordinates returned by Position: X
+	// towards heading 0 and Y to its left, with the origin where the motion controller started.
+	// Switching between heading hold and yaw-rate modes doesn't move the origin; only
+	// StopMotorControl does, since the next mode starts a new controller.
+	MoveTo(ctx context.Context, x, y float64) (achievedX, achievedY float64, err error)
+	Position() (x, y float64)
+	SetMoveProfile(p headingholder.MoveProfile)
 }
 
 type HeadingRelative interface {
@@ -82,7 +141,40 @@ func (d DistanceReadings) String() string {
 
 type Reading struct {
 	DistanceMM int
-	Error      error `json:"-"`
+	// CaptureTime is when this particular sensor was read; the sensors are read one after another so
+	// this is a little earlier than the CaptureTime of the DistanceReadings.
+	CaptureTime time.Time
+	Health      ReadingHealth
+	Error       error `json:"-"`
+}
+
+type ReadingHealth int
+
+const (
+	// ReadingOK means that DistanceMM is a valid measurement.
+	ReadingOK ReadingHealth = iota
+	// ReadingTooFar means that the sensor didn't see anything in range; DistanceMM is
+	// vl53l5cx.RangeTooFar.
+	ReadingTooFar
+	// ReadingFailed means that the sensor couldn't be read, see Error.  DistanceMM is 0.
+	ReadingFailed
+)
+
+func (h ReadingHealth) String() string {
+	switch h {
+	case ReadingOK:
+		return "ok"
+	case ReadingTooFar:
+		return "too-far"
+	case ReadingFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("ReadingHealth(%d)", int(h))
+}
+
+// Age returns how long ago the reading was taken.
+func (r Reading) Age() time.Duration {
+	return time.Since(r.CaptureTime)
 }
 
 func (r Reading) String() string {
@@ -100,6 +192,18 @@ type I2CInterface interface {
 	SetServo(n int, value float64)
 	SetPWM(n int, value float64)
 	CurrentDistanceReadings(revision revision) DistanceReadings
+	WaitForDistanceReadings(ctx context.Context, revision revision) (DistanceReadings, error)
 	AccumulatedRotations() picobldc.PerMotorVal[float64]
+	WheelVelocities() picobldc.WheelVelocities
+	PicoFaults() []PicoFault
+	SubscribePicoFaults(ctx context.Context) <-chan PicoFault
+	ClearPicoLockout()
+	ThermalStatus() ThermalStatus
+	BatteryStatus() BatteryStatus
+	// SetCurrentCeiling limits the traction battery current; 0 turns the limit off.
+	SetCurrentCeiling(amps float64)
+	// SetWheelSpeedLimit tells the loop how fast the motion controller lets the wheels turn, so that
+	// thermal derating can start from there; 0 means that there's no limit.
+	SetWheelSpeedLimit(rps float64)
 	Loop(context context.Context, initDone *sync.WaitGroup)
 }
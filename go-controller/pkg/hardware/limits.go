@@ -0,0 +1,147 @@
+package hardware
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder"
+)
+
+const (
+	motionLimitsConfigFile      = "/cfg/motion-limits.yaml"
+	motionLimitsConfigInUseFile = "/cfg/motion-limits-in-use.yaml"
+
+	// Names of the standard motion limits presets.
+	LimitsRC        = "rc"
+	LimitsChallenge = "challenge"
+	LimitsSpeedTest = "speedtest"
+
+	// currentLimitInterval is how often the I2C loop reads the traction current while a ceiling is in
+	// force; the usual power readings are far too slow.
+	currentLimitInterval = 50 * time.Millisecond
+	// When the current is over the ceiling, the speeds are scaled down in proportion, but never below
+	// minCurrentScale.  Once the current is back under the ceiling, the scale recovers at
+	// currentScaleRecoveryPerSec.
+	minCurrentScale            = 0.2
+	currentScaleRecoveryPerSec = 1.0
+)
+
+// MotionLimitsConfig holds the named presets that the modes choose from.
+type MotionLimitsConfig struct {
+	Presets map[string]headingholder.MotionLimits
+}
+
+func DefaultMotionLimitsConfig() MotionLimitsConfig {
+	challenge := headingholder.CarefulLimits()
+	challenge.MaxSpeedMMPerS = 1000
+
+	speedTest := headingholder.CarefulLimits()
+	speedTest.MaxAccelMMPerS2 = 3000
+	speedTest.MaxJerkMMPerS3 = 20000
+	speedTest.MaxWheelRPS = 10
+	speedTest.MaxBatteryAmps = 10
+
+	return MotionLimitsConfig{
+		Presets: map[string]headingholder.MotionLimits{
+			LimitsRC:        headingholder.RCLimits(),
+			LimitsChallenge: challenge,
+			LimitsSpeedTest: speedTest,
+		},
+	}
+}
+
+func loadMotionLimitsConfig() MotionLimitsConfig {
+	config := DefaultMotionLimitsConfig()
+	cfg, err := ioutil.ReadFile(motionLimitsConfigFile)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		// Presets in the file replace the defaults of the same name.
+		err = yaml.Unmarshal(cfg, &config)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	// Write out the config that we are using.
+	fmt.Printf("Limits: Using config: %#v\n", config)
+	cfgBytes, err := yaml.Marshal(&config)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		err = ioutil.WriteFile(motionLimitsConfigInUseFile, cfgBytes, 0666)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	return config
+}
+
+// currentLimiter keeps the traction battery current under a ceiling by scaling down the motor speeds.
+type currentLimiter struct {
+	lock        sync.Mutex
+	ceilingAmps float64
+	scale       float64
+	lastTime    time.Time
+}
+
+func newCurrentLimiter() *currentLimiter {
+	return &currentLimiter{scale: 1}
+}
+
+// setCeiling sets the ceiling; 0 turns the limit off.
+func (l *currentLimiter) setCeiling(amps float64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.ceilingAmps = amps
+	if amps <= 0 {
+		l.scale = 1
+	}
+}
+
+func (l *currentLimiter) active() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.ceilingAmps > 0
+}
+
+func (l *currentLimiter) record(now time.Time, amps float64) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	secs := now.Sub(l.lastTime).Seconds()
+	l.lastTime = now
+	if l.ceilingAmps <= 0 {
+		return
+	}
+
+	oldScale := l.scale
+	amps = math.Abs(amps)
+	if amps > l.ceilingAmps {
+		l.scale = max(minCurrentScale, l.scale*l.ceilingAmps/amps)
+	} else {
+		l.scale = min(1, l.scale+currentScaleRecoveryPerSec*min(secs, 1))
+	}
+	if l.scale < 1 && oldScale == 1 {
+		fmt.Printf("Limits: drawing %.1fA, over the %.1fA ceiling; slowing down\n", amps, l.ceilingAmps)
+	}
+}
+
+func (l *currentLimiter) limitSpeeds(fl, fr, bl, br int16) (int16, int16, int16, int16) {
+	l.lock.Lock()
+	scale := l.scale
+	l.lock.Unlock()
+
+	if scale >= 1 {
+		return fl, fr, bl, br
+	}
+	s := func(v int16) int16 {
+		return int16(float64(v) * scale)
+	}
+	return s(fl), s(fr), s(bl), s(br)
+}
@@ -0,0 +1,39 @@
+package hardware
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCurrentLimiter(t *testing.T) {
+	l := newCurrentLimiter()
+	start := time.Unix(1000, 0)
+
+	// No ceiling, no limit.
+	l.record(start, 20)
+	if fl, fr, bl, br := l.limitSpeeds(4096, -4096, 2048, 0); fl != 4096 || fr != -4096 || bl != 2048 || br != 0 {
+		t.Fatalf("Unexpected limiting without a ceiling: %v %v %v %v", fl, fr, bl, br)
+	}
+
+	l.setCeiling(10)
+	l.record(start.Add(50*time.Millisecond), 20)
+	if fl, fr, bl, br := l.limitSpeeds(4096, -4096, 2048, 0); fl != 2048 || fr != -2048 || bl != 1024 || br != 0 {
+		t.Fatalf("Expected speeds to be halved: %v %v %v %v", fl, fr, bl, br)
+	}
+
+	// Way over the ceiling; we slow to minCurrentScale rather than stopping altogether.
+	l.record(start.Add(100*time.Millisecond), 1000)
+	if fl, _, _, _ := l.limitSpeeds(4096, -4096, 2048, 0); fl != 819 {
+		t.Fatalf("Expected the minimum scale, got %v", fl)
+	}
+
+	// Under the ceiling, the speeds recover gradually.
+	l.record(start.Add(400*time.Millisecond), 5)
+	if fl, _, _, _ := l.limitSpeeds(4096, -4096, 2048, 0); fl <= 819 || fl >= 4096 {
+		t.Fatalf("Expected a partial recovery, got %v", fl)
+	}
+	l.record(start.Add(1400*time.Millisecond), 5)
+	if fl, _, _, _ := l.limitSpeeds(4096, -4096, 2048, 0); fl != 4096 {
+		t.Fatalf("Expected a full recovery, got %v", fl)
+	}
+}
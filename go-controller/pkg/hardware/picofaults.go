@@ -0,0 +1,304 @@
+package hardware
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/screen"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/telemetry"
+)
+
+const (
+	picoStatusPollInterval = 100 * time.Millisecond
+	// Faults are usually recovered from within one poll so we leave the notice up for a while to give
+	// the pit crew a chance to see it.
+	picoFaultNoticeTime = 5 * time.Second
+
+	// Enough for the post-run report, even if something goes badly wrong.
+	picoFaultHistoryLen = 100
+	picoFaultChanLen    = 16
+
+	// A stall or over-current tends to come straight back after a reset, so we reset after the first
+	// motor fault straight away but then back off, doubling the wait from picoResetBackoffMin each
+	// time.  After picoMaxMotorFaults motor faults within picoMotorFaultWindow, we stop resetting
+	// altogether and leave the motors stopped until the operator clears the lockout.
+	picoResetBackoffMin  = 200 * time.Millisecond
+	picoMotorFaultWindow = 30 * time.Second
+	picoMaxMotorFaults   = 5
+
+	NotePicoLockout = "PICO LOCKOUT"
+)
+
+type PicoFaultKind uint8
+
+const (
+	// PicoMotorFault means that the motor driver shut down, for example due to over-current.
+	PicoMotorFault PicoFaultKind = iota
+	// PicoWatchdogExpired means that the Pico stopped the motors because we didn't update them in
+	// time; the I2C loop was held up or the bus was down.
+	PicoWatchdogExpired
+	// PicoRebooted means that the Pico lost its configuration, usually due to a brown out.
+	PicoRebooted
+)
+
+func (k PicoFaultKind) String() string {
+	switch k {
+	case PicoMotorFault:
+		return "motor-fault"
+	case PicoWatchdogExpired:
+		return "watchdog-expired"
+	case PicoRebooted:
+		return "rebooted"
+	}
+	return fmt.Sprintf("PicoFaultKind(%d)", int(k))
+}
+
+var picoFaultNotices = map[PicoFaultKind]string{
+	PicoMotorFault:      "PICO FAULT",
+	PicoWatchdogExpired: "PICO WDOG",
+	PicoRebooted:        "PICO REBOOT",
+}
+
+type PicoFault struct {
+	Time       time.Time
+	Kind       PicoFaultKind
+	Status     picobldc.StatusFlag
+	FaultCount uint16
+	// Recovered is false if the I2C loop failed to reset the Pico, in which case it will reopen it
+	// from scratch, or if it has had too many motor faults and the loop has stopped resetting it.
+	Recovered bool
+	Err       string `json:",omitempty"`
+}
+
+func (f PicoFault) String() string {
+	s := fmt.Sprintf("%s %v status=%04x faults=%d", f.Time.Format("15:04:05.000"), f.Kind, uint16(f.Status), f.FaultCount)
+	if !f.Recovered {
+		s += " NOT RECOVERED: " + f.Err
+	}
+	return s
+}
+
+// picoFaultLog keeps the fault history and fans the faults out to subscribers.
+type picoFaultLog struct {
+	lock        sync.Mutex
+	history     []PicoFault
+	subscribers map[chan PicoFault]struct{}
+	lastNotice  map[PicoFaultKind]time.Time
+
+	// recentMotorFaults holds the times of the motor faults within picoMotorFaultWindow.  While
+	// motorFaultPending, we've seen a motor fault and are waiting until nextReset to reset the Pico.
+	recentMotorFaults []time.Time
+	motorFaultPending bool
+	nextReset         time.Time
+	lockedOut         bool
+}
+
+func (l *picoFaultLog) record(f PicoFault) {
+	fmt.Println("Pico:", f)
+	telemetry.RecordPicoFault(uint8(f.Kind), uint16(f.Status), f.FaultCount, f.Recovered)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.history = append(l.history, f)
+	if len(l.history) > picoFaultHistoryLen {
+		l.history = l.history[len(l.history)-picoFaultHistoryLen:]
+	}
+	for c := range l.subscribers {
+		select {
+		case c <- f:
+		default:
+			// Subscriber isn't keeping up; it can catch up from the history.
+		}
+	}
+
+	if l.lastNotice == nil {
+		l.lastNotice = map[PicoFaultKind]time.Time{}
+	}
+	l.lastNotice[f.Kind] = f.Time
+	screen.SetNotice(picoFaultNotices[f.Kind], screen.LevelErr)
+}
+
+// expireNotices clears the notices for faults that haven't recurred for a while.
+func (l *picoFaultLog) expireNotices(now time.Time) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for kind, t := range l.lastNotice {
+		if now.Sub(t) > picoFaultNoticeTime {
+			screen.ClearNotice(picoFaultNotices[kind])
+			delete(l.lastNotice, kind)
+		}
+	}
+}
+
+func (l *picoFaultLog) History() []PicoFault {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return append([]PicoFault(nil), l.history...)
+}
+
+// Subscribe returns a channel that receives each new fault until the context is cancelled, at which
+// point it is closed.  Faults are dropped if the channel is full.
+func (l *picoFaultLog) Subscribe(ctx context.Context) <-chan PicoFault {
+	c := make(chan PicoFault, picoFaultChanLen)
+	l.lock.Lock()
+	if l.subscribers == nil {
+		l.subscribers = map[chan PicoFault]struct{}{}
+	}
+	l.subscribers[c] = struct{}{}
+	l.lock.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		delete(l.subscribers, c)
+		close(c)
+	}()
+	return c
+}
+
+// LockedOut returns true if there have been too many motor faults and the Pico has been left faulted.
+func (l *picoFaultLog) LockedOut() bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.lockedOut
+}
+
+// ClearLockout lets the I2C loop reset the Pico again after too many motor faults.
+func (l *picoFaultLog) ClearLockout() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if !l.lockedOut {
+		return
+	}
+	fmt.Println("Pico: motor fault lockout cleared")
+	l.lockedOut = false
+	l.recentMotorFaults = nil
+	l.motorFaultPending = false
+	screen.ClearNotice(NotePicoLockout)
+}
+
+// onMotorFault notes a motor fault and works out what to do about it: returns reset=true if it's
+// time to reset the Pico, or lockOut=true if this fault is one too many.
+func (l *picoFaultLog) onMotorFault(now time.Time) (reset, lockOut bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.lockedOut {
+		return false, false
+	}
+	if !l.motorFaultPending {
+		l.motorFaultPending = true
+		recent := l.recentMotorFaults[:0]
+		for _, t := range l.recentMotorFaults {
+			if now.Sub(t) < picoMotorFaultWindow {
+				recent = append(recent, t)
+			}
+		}
+		l.recentMotorFaults = append(recent, now)
+
+		n := len(l.recentMotorFaults)
+		if n >= picoMaxMotorFaults {
+			l.lockedOut = true
+			l.motorFaultPending = false
+			return false, true
+		}
+		l.nextReset = now
+		if n > 1 {
+			backoff := picoResetBackoffMin << (n - 2)
+			l.nextReset = now.Add(backoff)
+			fmt.Printf("Pico: %d motor faults in the last %v, resetting in %v\n", n, picoMotorFaultWindow, backoff)
+		}
+	}
+	if now.Before(l.nextReset) {
+		return false, false
+	}
+	l.motorFaultPending = false
+	return true, false
+}
+
+// picoMonitor is the part of the driver that the fault polling needs.
+type picoMonitor interface {
+	Status() (picobldc.StatusFlag, error)
+	FaultCount() (uint16, error)
+	WatchdogTimeout() (time.Duration, error)
+	ClearStatus(flags picobldc.StatusFlag) error
+	Reset() error
+}
+
+// checkPico polls the Pico's status and recovers from any fault, returning the fault that it found,
+// if any.  The motor speeds need to be sent again after a fault.  An error means that the Pico
+// couldn't be recovered.
+func (l *picoFaultLog) checkPico(pico picoMonitor) (*PicoFault, error) {
+	now := time.Now()
+	l.expireNotices(now)
+
+	status, err := pico.Status()
+	if err != nil {
+		fmt.Println("Pico: failed to read status, will retry", err)
+		return nil, nil
+	}
+	timeout, err := pico.WatchdogTimeout()
+	if err != nil {
+		fmt.Println("Pico: failed to read watchdog timeout, will retry", err)
+		return nil, nil
+	}
+
+	var kind PicoFaultKind
+	switch {
+	case timeout == 0:
+		// We always run with the watchdog armed so this means that the Pico has rebooted.
+		kind = PicoRebooted
+	case status&picobldc.RegStatusFault != 0:
+		kind = PicoMotorFault
+	case status&picobldc.RegStatusWatchdogExpired != 0:
+		kind = PicoWatchdogExpired
+	default:
+		return nil, nil
+	}
+
+	f := PicoFault{
+		Time:      now,
+		Kind:      kind,
+		Status:    status,
+		Recovered: true,
+	}
+	if kind == PicoMotorFault {
+		reset, lockOut := l.onMotorFault(now)
+		if lockOut {
+			f.FaultCount, _ = pico.FaultCount()
+			f.Recovered = false
+			f.Err = fmt.Sprintf("%d motor faults in %v, leaving the motors stopped", picoMaxMotorFaults, picoMotorFaultWindow)
+			l.record(f)
+			screen.SetNotice(NotePicoLockout, screen.LevelErr)
+			return &f, nil
+		}
+		if !reset {
+			return nil, nil
+		}
+	}
+	f.FaultCount, _ = pico.FaultCount()
+
+	if kind == PicoWatchdogExpired {
+		// The Pico has only stopped the motors; sending the speeds again restarts them.
+		err = pico.ClearStatus(picobldc.RegStatusWatchdogExpired)
+	} else {
+		err = pico.Reset()
+		// A reboot clears any motor fault that we were waiting to reset.
+		l.lock.Lock()
+		l.motorFaultPending = false
+		l.lock.Unlock()
+	}
+	if err != nil {
+		f.Recovered = false
+		f.Err = err.Error()
+	}
+	l.record(f)
+	return &f, err
+}
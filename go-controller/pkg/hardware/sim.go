@@ -0,0 +1,184 @@
+package hardware
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/kinematics"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/simbot"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/telemetry"
+)
+
+// SimHardware is a Hardware that drives a simulated robot instead of the real I2C devices and IMU.
+// The control modes are the same as on the real robot, only the I/O is replaced.
+type SimHardware struct {
+	Hardware
+
+	Robot *simbot.Robot
+}
+
+var _ Interface = (*SimHardware)(nil)
+
+func NewSim(arena simbot.Arena) *SimHardware {
+	robot := simbot.New(arena)
+	return &SimHardware{
+		Hardware: Hardware{
+			i2c:          newSimI2C(robot),
+			imu:          newHeadingService(robot.IMU()),
+			slip:         newSlipMonitor(kinematics.DefaultSlipConfig()),
+			limitsConfig: DefaultMotionLimitsConfig(),
+			gains:        DefaultHeadingGainsConfig(),
+			soundsToPlay: simSounds(),
+		},
+		Robot: robot,
+	}
+}
+
+func simSounds() chan string {
+	soundsToPlay := make(chan string, 1)
+	go func() {
+		for s := range soundsToPlay {
+			fmt.Println("Sim: playing sound", s)
+		}
+	}()
+	return soundsToPlay
+}
+
+const simToFInterval = 100 * time.Millisecond
+
+// simI2C stands in for the I2CController.
+type simI2C struct {
+	robot *simbot.Robot
+
+	lock sync.Mutex
+
+	pwmPorts         map[int]pwmTypes
+	revisionUpdated  *sync.Cond
+	nextRevision     revision
+	distanceReadings DistanceReadings
+	picoFaults       picoFaultLog // Always empty; there's no Pico to fail.
+}
+
+func newSimI2C(robot *simbot.Robot) *simI2C {
+	c := &simI2C{
+		robot:        robot,
+		pwmPorts:     map[int]pwmTypes{},
+		nextRevision: 1,
+	}
+	c.revisionUpdated = sync.NewCond(&c.lock)
+	return c
+}
+
+var _ I2CInterface = (*simI2C)(nil)
+
+func (c *simI2C) SetMotorSpeeds(frontLeft, frontRight, backLeft, backRight int16) error {
+	c.robot.SetMotorSpeeds(frontLeft, frontRight, backLeft, backRight)
+	telemetry.RecordMotorSpeeds(frontLeft, frontRight, backLeft, backRight)
+	return nil
+}
+
+func (c *simI2C) SetServo(n int, value float64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.pwmPorts[n] = servoPosition(value)
+}
+
+func (c *simI2C) SetPWM(n int, value float64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.pwmPorts[n] = pwmValue(value)
+}
+
+func (c *simI2C) CurrentDistanceReadings(rev revision) DistanceReadings {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for c.distanceReadings.Revision <= rev {
+		c.revisionUpdated.Wait()
+	}
+
+	return c.distanceReadings
+}
+
+func (c *simI2C) WaitForDistanceReadings(ctx context.Context, rev revision) (DistanceReadings, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return waitForDistanceReadings(ctx, c.revisionUpdated, &c.distanceReadings, rev)
+}
+
+func (c *simI2C) AccumulatedRotations() picobldc.PerMotorVal[float64] {
+	return c.robot.AccumulatedRotations()
+}
+
+func (c *simI2C) WheelVelocities() picobldc.WheelVelocities {
+	actual, commanded := c.robot.WheelRPS()
+	return picobldc.NewWheelVelocities(time.Now(), actual, commanded)
+}
+
+func (c *simI2C) PicoFaults() []PicoFault {
+	return c.picoFaults.History()
+}
+
+func (c *simI2C) SubscribePicoFaults(ctx context.Context) <-chan PicoFault {
+	return c.picoFaults.Subscribe(ctx)
+}
+
+func (c *simI2C) ClearPicoLockout() {
+	c.picoFaults.ClearLockout()
+}
+
+// ThermalStatus reports no readings; the simulated motors don't get hot.
+func (c *simI2C) ThermalStatus() ThermalStatus {
+	return ThermalStatus{}
+}
+
+// BatteryStatus reports no buses; the simulated robot doesn't model its batteries.
+func (c *simI2C) BatteryStatus() BatteryStatus {
+	return BatteryStatus{}
+}
+
+// SetCurrentCeiling does nothing; the simulation doesn't model the battery current.
+func (c *simI2C) SetCurrentCeiling(amps float64) {
+}
+
+// SetWheelSpeedLimit does nothing; the simulated motors don't get hot so they're never derated.
+func (c *simI2C) SetWheelSpeedLimit(rps float64) {
+}
+
+func (c *simI2C) Loop(ctx context.Context, initDone *sync.WaitGroup) {
+	fmt.Println("Sim loop started")
+	go c.robot.Run(ctx)
+	initDone.Done()
+
+	ticker := time.NewTicker(simToFInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			c.publishDistanceReadings(now)
+		}
+	}
+}
+
+func (c *simI2C) publishDistanceReadings(now time.Time) {
+	var readings []Reading
+	for _, mm := range c.robot.DistanceReadingsMM() {
+		readings = append(readings, goodReading(mm))
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.distanceReadings = DistanceReadings{
+		CaptureTime: now,
+		Readings:    readings,
+		Revision:    c.nextRevision,
+	}
+	c.nextRevision++
+	c.revisionUpdated.Broadcast()
+}
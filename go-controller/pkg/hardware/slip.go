@@ -0,0 +1,115 @@
+package hardware
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/bno08x"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/kinematics"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/telemetry"
+)
+
+const (
+	slipConfigFile      = "/cfg/slip.yaml"
+	slipConfigInUseFile = "/cfg/slip-in-use.yaml"
+
+	// Long enough to cover any single challengemode move.
+	slipHistoryTime = 10 * time.Second
+)
+
+func loadSlipConfig() kinematics.SlipConfig {
+	config := kinematics.DefaultSlipConfig()
+	cfg, err := ioutil.ReadFile(slipConfigFile)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		err = yaml.Unmarshal(cfg, &config)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	// Write out the config that we are using.
+	fmt.Printf("Slip: Using config: %#v\n", config)
+	cfgBytes, err := yaml.Marshal(&config)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		err = ioutil.WriteFile(slipConfigInUseFile, cfgBytes, 0666)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	return config
+}
+
+type odometer interface {
+	AccumulatedRotations() picobldc.PerMotorVal[float64]
+}
+
+// slipMonitor runs the slip detector on every IMU report and keeps the recent verdicts.
+type slipMonitor struct {
+	detector *kinematics.SlipDetector
+
+	lock    sync.Mutex
+	history []kinematics.SlipStatus
+}
+
+func newSlipMonitor(config kinematics.SlipConfig) *slipMonitor {
+	return &slipMonitor{detector: kinematics.NewSlipDetector(kinematics.Default(), config)}
+}
+
+func (s *slipMonitor) loop(ctx context.Context, imu *headingService, wheels odometer) {
+	// Poll rather than using WaitForReportAfter, which can't be cancelled, so that we exit at shut
+	// down even if the IMU has stopped.
+	var last time.Time
+	for ctx.Err() == nil {
+		r := imu.CurrentReport()
+		if r.Time.IsZero() || !r.Time.After(last) {
+			time.Sleep(bno08x.ReportInterval / 4)
+			continue
+		}
+		last = r.Time
+		s.record(s.detector.Update(r.Time, wheels.AccumulatedRotations(), r.RobotYaw()))
+	}
+}
+
+func (s *slipMonitor) record(status kinematics.SlipStatus) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	wasSlipping := len(s.history) > 0 && s.history[len(s.history)-1].Slipping
+	if status.Slipping != wasSlipping {
+		if status.Slipping {
+			fmt.Printf("Slip: wheels slipping, odometry turned %.1f degrees, IMU %.1f\n",
+				status.OdometryYawDegrees, status.IMUYawDegrees)
+		} else {
+			fmt.Println("Slip: wheels gripping again")
+		}
+		telemetry.RecordWheelSlip(status.OdometryYawDegrees, status.IMUYawDegrees, status.Confidence)
+	}
+
+	s.history = append(s.history, status)
+	for len(s.history) > 0 && status.Time.Sub(s.history[0].Time) > slipHistoryTime {
+		s.history = s.history[1:]
+	}
+}
+
+// WorstSince returns the verdict with the lowest confidence since the given time.
+func (s *slipMonitor) WorstSince(t time.Time) kinematics.SlipStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	worst := kinematics.SlipStatus{Time: t, Confidence: 1}
+	for _, status := range s.history {
+		if status.Time.After(t) && status.Confidence < worst.Confidence {
+			worst = status
+		}
+	}
+	return worst
+}
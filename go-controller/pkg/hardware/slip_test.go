@@ -0,0 +1,30 @@
+package hardware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/kinematics"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/simbot"
+)
+
+func TestSlipMonitorExitsWithoutIMUReports(t *testing.T) {
+	// The robot isn't running so its IMU never reports.
+	robot := simbot.New(simbot.DefaultArena())
+	s := newSlipMonitor(kinematics.DefaultSlipConfig())
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.loop(ctx, newHeadingService(robot.IMU()), newSimI2C(robot))
+		close(done)
+	}()
+	// Give it time to start waiting for a report.
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Slip monitor didn't exit when cancelled")
+	}
+}
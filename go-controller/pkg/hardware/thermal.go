@@ -0,0 +1,174 @@
+package hardware
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/kinematics"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/screen"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/telemetry"
+)
+
+const (
+	thermalConfigFile      = "/cfg/thermal.yaml"
+	thermalConfigInUseFile = "/cfg/thermal-in-use.yaml"
+
+	NoteHot = "DRIVER HOT"
+)
+
+// ThermalConfig is the thermal protection policy for the motor drivers.  Between DerateStartC and
+// DerateFullC, the fastest that any wheel may turn drops linearly from the wheel speed limit of the
+// active motion limits to FullLimitRPS; it stays at FullLimitRPS above that.  Since the limit starts
+// from the speed that the wheels were already capped at and follows the temperature smoothly, the
+// bot slows down gradually rather than cutting out.
+type ThermalConfig struct {
+	DerateStartC float64
+	DerateFullC  float64
+
+	// StartLimitRPS stands in for the wheel speed limit when there isn't one, such as in raw mode.
+	StartLimitRPS float64
+	FullLimitRPS  float64
+
+	// Once started, derating (at the starting limit or lower) carries on until the temperature drops
+	// HysteresisC below DerateStartC, so that we don't flap in and out of it.
+	HysteresisC float64
+}
+
+func DefaultThermalConfig() ThermalConfig {
+	return ThermalConfig{
+		DerateStartC:  60,
+		DerateFullC:   80,
+		StartLimitRPS: 10, // The fastest of the motion limits presets.
+		FullLimitRPS:  1.5,
+		HysteresisC:   5,
+	}
+}
+
+func loadThermalConfig() ThermalConfig {
+	config := DefaultThermalConfig()
+	cfg, err := ioutil.ReadFile(thermalConfigFile)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		err = yaml.Unmarshal(cfg, &config)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	// Write out the config that we are using.
+	fmt.Printf("Thermal: Using config: %#v\n", config)
+	cfgBytes, err := yaml.Marshal(&config)
+	if err != nil {
+		fmt.Println(err)
+	} else {
+		err = ioutil.WriteFile(thermalConfigInUseFile, cfgBytes, 0666)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	return config
+}
+
+type ThermalStatus struct {
+	Time         time.Time // Of the last reading.
+	TemperatureC float64
+	// Derating is true while the speed limit is in force.  LimitRPS is the limit.
+	Derating bool
+	LimitRPS float64
+}
+
+// thermalMonitor tracks the Pico's temperature and works out the speed limit.
+type thermalMonitor struct {
+	config ThermalConfig
+
+	lock   sync.Mutex
+	status ThermalStatus
+	// wheelLimitRPS is the wheel speed limit of the active motion limits, or 0 if there isn't one.
+	wheelLimitRPS float64
+}
+
+func newThermalMonitor(config ThermalConfig) *thermalMonitor {
+	return &thermalMonitor{config: config}
+}
+
+// setWheelLimit tells the monitor the wheel speed limit that derating starts from; 0 means that
+// there isn't one.
+func (t *thermalMonitor) setWheelLimit(rps float64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.wheelLimitRPS = rps
+	if t.status.Derating {
+		t.status.LimitRPS = t.limitFor(t.status.TemperatureC)
+	}
+}
+
+func (t *thermalMonitor) record(now time.Time, tempC float64) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	wasDerating, oldLimit := t.status.Derating, t.status.LimitRPS
+	t.status.Time = now
+	t.status.TemperatureC = tempC
+	if wasDerating {
+		t.status.Derating = tempC >= t.config.DerateStartC-t.config.HysteresisC
+	} else {
+		t.status.Derating = tempC > t.config.DerateStartC
+	}
+	t.status.LimitRPS = 0
+	if t.status.Derating {
+		t.status.LimitRPS = t.limitFor(tempC)
+	}
+
+	switch {
+	case t.status.Derating && !wasDerating:
+		fmt.Printf("Thermal: driver at %.1fC, derating to %.2f RPS\n", tempC, t.status.LimitRPS)
+		screen.SetNotice(NoteHot, screen.LevelErr)
+	case !t.status.Derating && wasDerating:
+		fmt.Printf("Thermal: driver cooled to %.1fC, derating ended\n", tempC)
+		screen.ClearNotice(NoteHot)
+	case t.status.Derating && math.Abs(t.status.LimitRPS-oldLimit) >= 0.5:
+		fmt.Printf("Thermal: driver at %.1fC, limit now %.2f RPS\n", tempC, t.status.LimitRPS)
+	default:
+		return
+	}
+	telemetry.RecordThermal(tempC, t.status.LimitRPS)
+}
+
+// limitFor returns the speed limit at the given temperature.  The caller must hold the lock.
+func (t *thermalMonitor) limitFor(tempC float64) float64 {
+	c := t.config
+	start := c.StartLimitRPS
+	if t.wheelLimitRPS > 0 {
+		start = t.wheelLimitRPS
+	}
+	switch {
+	case tempC <= c.DerateStartC:
+		return start
+	case tempC >= c.DerateFullC:
+		return min(start, c.FullLimitRPS)
+	}
+	frac := (tempC - c.DerateStartC) / (c.DerateFullC - c.DerateStartC)
+	return min(start, start+(c.FullLimitRPS-start)*frac)
+}
+
+func (t *thermalMonitor) Status() ThermalStatus {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.status
+}
+
+// limitSpeeds applies the speed limit to a set of motor speeds, scaling them down together so that
+// the bot still goes in the same direction.
+func (t *thermalMonitor) limitSpeeds(fl, fr, bl, br int16) (int16, int16, int16, int16) {
+	s := t.Status()
+	if !s.Derating {
+		return fl, fr, bl, br
+	}
+	return kinematics.LimitMotorSpeeds(fl, fr, bl, br, s.LimitRPS)
+}
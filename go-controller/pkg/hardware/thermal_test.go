@@ -0,0 +1,75 @@
+package hardware
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder"
+)
+
+func TestThermalDeratingIsSmooth(t *testing.T) {
+	th := newThermalMonitor(DefaultThermalConfig())
+	th.setWheelLimit(headingholder.CarefulLimits().MaxWheelRPS)
+
+	for _, tc := range []struct {
+		tempC    float64
+		derating bool
+		limitRPS float64
+	}{
+		{40, false, 0},
+		{60, false, 0},
+		{65, true, 4.125},
+		{70, true, 3.25},
+		{80, true, 1.5},
+		{95, true, 1.5},
+		// Cooling down retraces the curve...
+		{70, true, 3.25},
+		// ...and stays at the start limit until the driver is properly cool.
+		{57, true, 5},
+		{54, false, 0},
+	} {
+		th.record(time.Now(), tc.tempC)
+		s := th.Status()
+		if s.Derating != tc.derating || math.Abs(s.LimitRPS-tc.limitRPS) > 1e-9 {
+			t.Errorf("At %.0fC expected derating=%v limit %.3f, got %+v", tc.tempC, tc.derating, tc.limitRPS, s)
+		}
+	}
+}
+
+func TestThermalDeratingStartsFromRCLimit(t *testing.T) {
+	th := newThermalMonitor(DefaultThermalConfig())
+	rcLimit := DefaultMotionLimitsConfig().Presets[LimitsRC].MaxWheelRPS
+	th.setWheelLimit(rcLimit)
+
+	// Warm up past the start of derating in small steps; the wheel speed cap shouldn't jump.
+	lastCap := rcLimit
+	for tempC := 55.0; tempC <= 65; tempC += 0.1 {
+		th.record(time.Now(), tempC)
+		wheelCap := rcLimit
+		if s := th.Status(); s.Derating {
+			wheelCap = min(rcLimit, s.LimitRPS)
+		}
+		if math.Abs(wheelCap-lastCap) > 0.1 {
+			t.Fatalf("Wheel speed cap jumped from %.2f to %.2f RPS at %.1fC", lastCap, wheelCap, tempC)
+		}
+		lastCap = wheelCap
+	}
+	if lastCap >= rcLimit {
+		t.Fatalf("Expected to be derating at 65C, cap is %.2f RPS", lastCap)
+	}
+}
+
+func TestThermalLimitSpeeds(t *testing.T) {
+	th := newThermalMonitor(DefaultThermalConfig())
+	th.record(time.Now(), 30)
+	if fl, fr, bl, br := th.limitSpeeds(4096, -4096, 2048, 0); fl != 4096 || fr != -4096 || bl != 2048 || br != 0 {
+		t.Errorf("Speeds limited while cool: %d %d %d %d", fl, fr, bl, br)
+	}
+
+	th.record(time.Now(), 80)
+	fl, fr, bl, br := th.limitSpeeds(4096, -4096, 2048, 0)
+	if fl != 1536 || fr != -1536 || bl != 768 || br != 0 {
+		t.Errorf("Expected speeds scaled to 1.5 RPS, got %d %d %d %d", fl, fr, bl, br)
+	}
+}
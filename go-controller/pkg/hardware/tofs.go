@@ -0,0 +1,257 @@
+package hardware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/mux"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/screen"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/vl53l5cx"
+)
+
+const (
+	// The time-of-flight sensors all share the same address so they hang off the mux on their own bus.
+	tofDeviceFile = "/dev/i2c-2"
+
+	tofRetryInterval    = time.Second
+	tofDisabledInterval = 100 * time.Millisecond
+
+	// distanceReadingsTimeout is how long WaitForDistanceReadings waits for a new sweep before
+	// declaring the readings stale.  A sweep normally takes ~100ms but a sensor that has just died can
+	// hold it up for a second or so.
+	distanceReadingsTimeout = 2 * time.Second
+)
+
+var ErrDistanceReadingsStale = errors.New("no fresh distance readings")
+
+// tofMuxPorts lists the mux port of each sensor, in the same order as DistanceReadings.Readings.
+var tofMuxPorts = []int{
+	mux.BusTOFLeftRear,
+	mux.BusTOFLeftFront,
+	mux.BusTOFForwardLeft,
+	mux.BusTOFForwardRight,
+	mux.BusTOFRightFront,
+	mux.BusTOFRightRear,
+}
+
+var errToFNotOpen = errors.New("sensor not open")
+
+type tofSensor struct {
+	port int
+	tof  vl53l5cx.Interface
+
+	lastOpenAttempt time.Time
+}
+
+func (c *I2CController) toFsEnabled() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.tofsEnabled
+}
+
+// tofLoop reads the time-of-flight sensors round-robin, publishing a new revision of the distance
+// readings after each sweep.  Sensors that fail are closed and periodically re-opened; the other
+// sensors carry on as normal.
+func (c *I2CController) tofLoop(ctx context.Context) {
+	fmt.Println("ToF loop started")
+
+	var sensors []*tofSensor
+	for _, port := range tofMuxPorts {
+		sensors = append(sensors, &tofSensor{port: port})
+	}
+
+	var tofMux mux.Interface
+	defer func() {
+		for _, s := range sensors {
+			s.close()
+		}
+		if tofMux != nil {
+			_ = tofMux.Close()
+		}
+	}()
+
+	for ctx.Err() == nil {
+		if !c.toFsEnabled() {
+			time.Sleep(tofDisabledInterval)
+			continue
+		}
+
+		if tofMux == nil {
+			var err error
+			tofMux, err = mux.New(tofDeviceFile)
+			if err != nil {
+				fmt.Println("Failed to open ToF mux", err)
+				screen.SetNotice(NoteTOFs, screen.LevelErr)
+				tofMux = nil
+				time.Sleep(tofRetryInterval)
+				continue
+			}
+		}
+
+		readings := make([]Reading, len(sensors))
+		var muxErr error
+		failed := false
+		for i, s := range sensors {
+			if err := tofMux.SelectSinglePort(s.port); err != nil {
+				muxErr = err
+				readings[i] = failedReading(err)
+				failed = true
+				continue
+			}
+			mm, err := s.read()
+			if err != nil {
+				if err != errToFNotOpen {
+					fmt.Printf("ToF sensor on mux port %d failed: %v\n", s.port, err)
+				}
+				readings[i] = failedReading(err)
+				failed = true
+				continue
+			}
+			readings[i] = goodReading(mm)
+		}
+		_ = tofMux.DisableAllPorts()
+
+		if muxErr != nil {
+			fmt.Println("Failed to select ToF mux port, will re-open the mux", muxErr)
+			_ = tofMux.Close()
+			tofMux = nil
+		}
+		if failed {
+			screen.SetNotice(NoteTOFs, screen.LevelErr)
+		} else {
+			screen.ClearNotice(NoteTOFs)
+		}
+
+		c.publishDistanceReadings(time.Now(), readings)
+
+		if muxErr != nil {
+			time.Sleep(tofRetryInterval)
+		}
+	}
+}
+
+func goodReading(mm int) Reading {
+	r := Reading{
+		DistanceMM:  mm,
+		CaptureTime: time.Now(),
+		Health:      ReadingOK,
+	}
+	if mm >= vl53l5cx.RangeTooFar {
+		r.Health = ReadingTooFar
+	}
+	return r
+}
+
+func failedReading(err error) Reading {
+	return Reading{
+		CaptureTime: time.Now(),
+		Health:      ReadingFailed,
+		Error:       err,
+	}
+}
+
+func (c *I2CController) WaitForDistanceReadings(ctx context.Context, rev revision) (DistanceReadings, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return waitForDistanceReadings(ctx, c.revisionUpdated, &c.distanceReadings, rev)
+}
+
+// waitForDistanceReadings waits on cond until *readings has a revision newer than rev, the context is
+// cancelled or the readings go stale.  The cond's lock must be held.
+func waitForDistanceReadings(ctx context.Context, cond *sync.Cond, readings *DistanceReadings, rev revision) (DistanceReadings, error) {
+	timedOut := false
+	timer := time.AfterFunc(distanceReadingsTimeout, func() {
+		cond.L.Lock()
+		defer cond.L.Unlock()
+		timedOut = true
+		cond.Broadcast()
+	})
+	defer timer.Stop()
+	stop := context.AfterFunc(ctx, func() {
+		cond.L.Lock()
+		defer cond.L.Unlock()
+		cond.Broadcast()
+	})
+	defer stop()
+
+	for readings.Revision <= rev {
+		if err := ctx.Err(); err != nil {
+			return DistanceReadings{}, err
+		}
+		if timedOut {
+			return DistanceReadings{}, ErrDistanceReadingsStale
+		}
+		cond.Wait()
+	}
+	if time.Since(readings.CaptureTime) > distanceReadingsTimeout {
+		// Asked for RevCurrent (or an old revision) but the sensors have stopped since.
+		return *readings, ErrDistanceReadingsStale
+	}
+	return *readings, nil
+}
+
+func (c *I2CController) publishDistanceReadings(captureTime time.Time, readings []Reading) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.distanceReadings = DistanceReadings{
+		CaptureTime: captureTime,
+		Readings:    readings,
+		Revision:    c.nextRevision,
+	}
+	c.nextRevision++
+	c.revisionUpdated.Broadcast()
+}
+
+// read takes the next measurement from the sensor, (re-)opening it first if needed.  The sensor's
+// mux port must already be selected.
+func (s *tofSensor) read() (int, error) {
+	if s.tof == nil {
+		if time.Since(s.lastOpenAttempt) < tofRetryInterval {
+			return 0, errToFNotOpen
+		}
+		s.lastOpenAttempt = time.Now()
+		tof, err := vl53l5cx.New(tofDeviceFile)
+		if err != nil {
+			return 0, err
+		}
+		if err := tof.StartContinuousMeasurements(); err != nil {
+			_ = tof.Close()
+			return 0, err
+		}
+		fmt.Printf("Opened ToF sensor on mux port %d\n", s.port)
+		s.tof = tof
+	}
+
+	zones, err := s.tof.GetNextContinuousMeasurement()
+	if err != nil {
+		s.close()
+		return 0, err
+	}
+	return summariseZones(zones), nil
+}
+
+func (s *tofSensor) close() {
+	if s.tof == nil {
+		return
+	}
+	_ = s.tof.Close()
+	s.tof = nil
+}
+
+// summariseZones reduces the sensor's 4x4 grid of zones to a single distance: the nearest object in
+// the middle two rows.  The top and bottom rows are ignored since they tend to see the floor or
+// objects that are too high to hit.
+func summariseZones(zones []int) int {
+	nearest := vl53l5cx.RangeTooFar
+	for i := 4; i < 12 && i < len(zones); i++ {
+		if zones[i] > 0 && zones[i] < nearest {
+			nearest = zones[i]
+		}
+	}
+	return nearest
+}
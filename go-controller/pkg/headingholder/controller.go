@@ -0,0 +1,449 @@
+package headingholder
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/kinematics"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/bno08x"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder/angle"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/telemetry"
+)
+
+// ControlMode says how the Controller decides which way the bot should face.  The mode follows the
+// setpoints: SetYawAndThrottle switches to YawRateMode; SetHeading, SetHeadingWithProfile and
+// AddHeadingDelta switch to HeadingMode.  The throttle is independent of the mode.
+type ControlMode int
+
+const (
+	// YawRateMode turns the bot at the rate set with SetYawAndThrottle, holding the heading when the
+	// rate is zero.  A new Controller starts in this mode, holding the heading it starts at.
+	YawRateMode ControlMode = iota
+	// HeadingMode turns the bot to the target heading and holds it there.
+	HeadingMode
+)
+
+func (m ControlMode) String() string {
+	switch m {
+	case YawRateMode:
+		return "yaw-rate"
+	case HeadingMode:
+		return "heading"
+	}
+	return fmt.Sprintf("ControlMode(%d)", int(m))
+}
+
+func NewController(motors RawControl) *Controller {
+	hh := &Controller{
+		Motors:     motors,
+		Kinematics: kinematics.Default(),
+	}
+	hh.limits = CarefulLimits()
+	hh.gains = DefaultAbsoluteGains()
+	hh.onNewReading = sync.NewCond(&hh.controlLock)
+	return hh
+}
+
+// Controller drives the bot, holding its heading with a PID.  The heading comes either from an
+// absolute target or from integrating a yaw rate, see ControlMode.  Both modes share the target
+// heading and the PID and speed state, so switching between them mid-motion is bumpless: a switch to
+// yaw-rate control carries on from the heading that the bot was aiming for and a profiled turn
+// started while driving by yaw rate picks up from the current target and rate.
+type Controller struct {
+	Motors     RawControl
+	Kinematics kinematics.Model
+	// IMU to read the heading from.  If nil, the loop opens the BNO08X on the serial port.
+	IMU bno08x.Interface
+	// Reference supplies heading 0.  If nil, the heading is zeroed at the start of the loop.
+	Reference HeadingReference
+
+	onNewReading *sync.Cond
+
+	controlLock sync.Mutex
+	controls
+	lastReadingTime time.Time
+	// loopStopped is set when the loop exits, so that anything waiting for a reading can give up.
+	loopStopped bool
+	// Position from the wheel rotations; see Position.
+	x, y        float64
+	moveProfile MoveProfile
+}
+
+type controls struct {
+	mode               ControlMode
+	targetHeading      angle.PlusMinus180
+	currentHeading     angle.PlusMinus180
+	yawRateDegreesPerS float64
+	throttleMMPerS     float64
+	translationMMPerS  float64
+	limits             MotionLimits
+	gains              Gains
+
+	// turning is true while a profiled turn's reference heading is on its way to targetHeading.
+	// turnID changes with each call to SetHeadingWithProfile so the loop can spot a new turn.
+	turning     bool
+	turnID      int
+	turnProfile TurnProfile
+}
+
+// SetYawAndThrottle switches to YawRateMode and sets the yaw rate, throttle and translation from
+// stick positions between -1 and 1.
+func (h *Controller) SetYawAndThrottle(yawRate, throttle, translation float64) {
+	h.controlLock.Lock()
+	defer h.controlLock.Unlock()
+
+	telemetry.RecordSetYawAndThrottle(yawRate, throttle, translation)
+	h.mode = YawRateMode
+	h.turning = false
+	h.yawRateDegreesPerS = yawRate * 500
+	h.throttleMMPerS = throttle * 800
+	h.translationMMPerS = translation * 800
+}
+
+func (h *Controller) SetHeading(desiredHeaading float64) {
+	h.controlLock.Lock()
+	defer h.controlLock.Unlock()
+
+	telemetry.RecordSetHeading(desiredHeaading)
+	h.mode = HeadingMode
+	h.targetHeading = angle.FromFloat(desiredHeaading)
+	h.turning = false
+}
+
+// SetHeadingWithProfile turns the bot to the given heading smoothly: rather than the PID chasing a
+// step change, it follows a reference heading that accelerates at up to maxAccel (degrees/s²) to
+// maxRate (degrees/s) and then slows down to stop at the target.  The rate of the reference (and its
+// acceleration, to allow for the motors' lag) is fed forward, so the PID only has to correct the
+// tracking error.  A new turn starts from wherever the previous one had got to.  A zero rate or
+// acceleration means a step, like SetHeading.
+func (h *Controller) SetHeadingWithProfile(desiredHeading, maxRate, maxAccel float64) {
+	h.controlLock.Lock()
+	defer h.controlLock.Unlock()
+
+	telemetry.RecordSetHeadingWithProfile(desiredHeading, maxRate, maxAccel)
+	h.mode = HeadingMode
+	h.targetHeading = angle.FromFloat(desiredHeading)
+	h.turning = maxRate > 0 && maxAccel > 0
+	h.turnID++
+	h.turnProfile = TurnProfile{MaxRateDegreesPerS: maxRate, MaxAccelDegreesPerS2: maxAccel}
+}
+
+// AddHeadingDelta switches to HeadingMode and moves the target heading by delta.  In YawRateMode the
+// target is wherever the yaw rate had got to.
+func (h *Controller) AddHeadingDelta(delta float64) {
+	h.controlLock.Lock()
+	defer h.controlLock.Unlock()
+	telemetry.RecordAddHeadingDelta(delta)
+	h.mode = HeadingMode
+	h.targetHeading = h.targetHeading.AddFloat(delta)
+}
+
+// HoldHeading switches to HeadingMode without changing the target, so the bot carries on to and
+// then holds the heading it was already aiming for.  It's recorded as a zero AddHeadingDelta.
+func (h *Controller) HoldHeading() {
+	h.AddHeadingDelta(0)
+}
+
+// SetThrottle is equivalent to SetThrottleWithAngle with an angle of 0 (i.e. straight ahead)
+func (h *Controller) SetThrottle(throttle float64) {
+	h.SetThrottleWithAngle(throttle, 0)
+}
+
+func (h *Controller) SetThrottleWithAngle(throttleMMPerS, angle float64) {
+	h.controlLock.Lock()
+	defer h.controlLock.Unlock()
+	telemetry.RecordSetThrottleWithAngle(throttleMMPerS, angle)
+	angleRads := angle * 2 * math.Pi / 360
+	h.throttleMMPerS = throttleMMPerS * math.Cos(angleRads)
+	h.translationMMPerS = throttleMMPerS * math.Sin(angleRads)
+}
+
+func (h *Controller) SetLimits(l MotionLimits) {
+	h.controlLock.Lock()
+	defer h.controlLock.Unlock()
+
+	telemetry.RecordSetMotionLimits(telemetry.SetMotionLimits(l))
+	h.limits = l
+}
+
+func (h *Controller) Limits() MotionLimits {
+	h.controlLock.Lock()
+	defer h.controlLock.Unlock()
+
+	return h.limits
+}
+
+func (h *Controller) SetGains(g Gains) {
+	h.controlLock.Lock()
+	defer h.controlLock.Unlock()
+
+	telemetry.RecordSetHeadingGains(g.Kp, g.Ki, g.Kd, g.MaxIntegral, g.MaxD)
+	h.gains = g
+}
+
+func (h *Controller) Gains() Gains {
+	h.controlLock.Lock()
+	defer h.controlLock.Unlock()
+
+	return h.gains
+}
+
+func (h *Controller) Mode() ControlMode {
+	h.controlLock.Lock()
+	defer h.controlLock.Unlock()
+
+	return h.mode
+}
+
+func (h *Controller) CurrentHeading() angle.PlusMinus180 {
+	h.controlLock.Lock()
+	defer h.controlLock.Unlock()
+
+	return h.currentHeading
+}
+
+// TargetHeading returns the heading that the bot is aiming for.  In YawRateMode, it moves with the
+// yaw rate.
+func (h *Controller) TargetHeading() angle.PlusMinus180 {
+	h.controlLock.Lock()
+	defer h.controlLock.Unlock()
+
+	return h.targetHeading
+}
+
+func (h *Controller) Loop(cxt context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer fmt.Println("Heading holder loop exited")
+	h.controlLock.Lock()
+	h.loopStopped = false
+	h.controlLock.Unlock()
+	defer func() {
+		h.controlLock.Lock()
+		h.loopStopped = true
+		h.onNewReading.Broadcast()
+		h.controlLock.Unlock()
+	}()
+
+	m, imuReport, err := openIMU(cxt, h.IMU)
+	if err != nil {
+		return
+	}
+
+	initialHeading := imuReport.RobotYaw()
+	if h.Reference != nil {
+		initialHeading = h.Reference.ZeroYaw()
+	}
+	h.controlLock.Lock()
+	mode := h.mode
+	if mode == YawRateMode {
+		// Hold whatever heading we're starting at.
+		h.targetHeading = imuReport.RobotYaw().Sub(initialHeading)
+	}
+	lastReference := h.targetHeading
+	h.controlLock.Unlock()
+	telemetry.RecordHeadingHolderStart(imuReport.Time, mode == YawRateMode, imuReport.Telemetry(), initialHeading.Float())
+	fmt.Printf("HH: Starting in %v mode with gains %v, limits %+v\n", mode, h.Gains(), h.Limits())
+	defer telemetry.RecordHeadingHolderStop()
+	var headingEstimate angle.PlusMinus180
+	var throttleSlew, translationSlew slewLimiter
+	var lastHeadingError float64
+	var iHeadingError float64
+	var stalled bool
+	var turn turnTrajectory
+	var turnID int
+	var turnInProgress bool
+	var lastYawRate float64
+	odometer, haveOdometer := h.Motors.(Odometer)
+	var lastRotations picobldc.PerMotorVal[float64]
+	if haveOdometer {
+		lastRotations = odometer.AccumulatedRotations()
+	}
+
+	// Loop timing comes from the IMU reports rather than the wall clock so that replaying recorded
+	// reports reproduces the same motor commands.
+	var lastLoopStart = imuReport.Time
+
+	defer func() {
+		if err := h.Motors.SetMotorSpeeds(0, 0, 0, 0); err != nil {
+			fmt.Println("Failed to set motor speeds:", err)
+		}
+	}()
+	lastPrint := time.Now()
+	for cxt.Err() == nil {
+		// This should pop every 10ms
+		lastIMUReportTime := imuReport.Time
+		imuReport = m.WaitForReportAfter(lastIMUReportTime)
+
+		now := imuReport.Time
+		loopTime := now.Sub(lastLoopStart)
+		loopTimeSecs := loopTime.Seconds()
+		lastLoopStart = now
+
+		// We use an angle.PlusMinus180 to make sure we do our modulo arithmetic
+		// correctly...
+		headingEstimate = imuReport.RobotYaw().Sub(initialHeading)
+
+		// Track our position from the wheel rotations since the last loop.
+		var dx, dy float64
+		if haveOdometer {
+			rotations := odometer.AccumulatedRotations()
+			var delta picobldc.PerMotorVal[float64]
+			for m := range rotations {
+				delta[m] = rotations[m] - lastRotations[m]
+			}
+			lastRotations = rotations
+			moved := h.Kinematics.Motion(delta)
+			headingRads := headingEstimate.Float() * math.Pi / 180
+			sin, cos := math.Sin(headingRads), math.Cos(headingRads)
+			dx = moved.AheadMM*cos - moved.LeftMM*sin
+			dy = moved.AheadMM*sin + moved.LeftMM*cos
+		}
+
+		// Grab the current control values.
+		h.controlLock.Lock()
+		lastMode := mode
+		mode = h.mode
+		h.currentHeading = headingEstimate
+		h.lastReadingTime = now
+		h.x += dx
+		h.y += dy
+
+		var feedforwardDegreesPS float64
+		if mode == YawRateMode {
+			if lastMode == HeadingMode && turnInProgress {
+				// Carry on from where the turn had got to rather than jumping to its target.
+				h.targetHeading = turn.reference
+			}
+			// Update our target heading accordingly.
+			h.targetHeading = h.targetHeading.AddFloat(h.yawRateDegreesPerS * loopTimeSecs)
+
+			// Cap the difference between our actual angle and the target so that we can't
+			// accumulate the desire to spin around and around.
+			const maxLeadAngle = 20.0
+			leadAngle := h.targetHeading.Sub(headingEstimate).Float()
+			if leadAngle < -maxLeadAngle {
+				h.targetHeading = headingEstimate.SubFloat(maxLeadAngle)
+			} else if leadAngle > maxLeadAngle {
+				h.targetHeading = headingEstimate.AddFloat(maxLeadAngle)
+			}
+		}
+		controls := h.controls
+		limits := controls.limits
+		gains := controls.gains
+
+		// During a profiled turn, the PID follows the turn's reference heading rather than the
+		// target.
+		targetHeading := controls.targetHeading
+		reference := targetHeading
+		if h.turning {
+			if h.turnID != turnID {
+				switch {
+				case lastMode == YawRateMode:
+					// Pick up from the yaw rate's target, at the rate we were turning.
+					turn.reference = lastReference
+					turn.rate = max(-h.turnProfile.MaxRateDegreesPerS, min(h.turnProfile.MaxRateDegreesPerS, lastYawRate))
+				case !turnInProgress:
+					// Start from where we're facing.
+					turn.reference, turn.rate = headingEstimate, 0
+				}
+				// Otherwise, start from wherever the last turn had got to.
+				turn.profile = h.turnProfile
+				turnID = h.turnID
+			}
+			var done bool
+			reference, feedforwardDegreesPS, done = turn.step(targetHeading, loopTimeSecs)
+			h.turning = !done
+		}
+		turnInProgress = h.turning
+		lastReference = reference
+		h.onNewReading.Broadcast()
+		h.controlLock.Unlock()
+
+		if mode != lastMode {
+			fmt.Printf("HH: Switched to %v mode\n", mode)
+		}
+		// Calculate the error/derivative/integral.
+		headingErrorDegrees := reference.Sub(headingEstimate).Float()
+		if mode != lastMode {
+			// The target may jump when the mode changes; don't kick the derivative.
+			lastHeadingError = headingErrorDegrees
+		}
+		dHeadingError := (headingErrorDegrees - lastHeadingError) / loopTimeSecs
+		if dHeadingError > gains.MaxD {
+			dHeadingError = gains.MaxD
+		} else if dHeadingError < -gains.MaxD {
+			dHeadingError = -gains.MaxD
+		}
+
+		if s := wheelsStalled(h.Motors); s != stalled {
+			stalled = s
+			if stalled {
+				fmt.Println("HH: wheel stalled, holding integral")
+			} else {
+				fmt.Println("HH: wheels turning again")
+			}
+		}
+		if math.Abs(headingErrorDegrees) < 5 {
+			if !stalled {
+				iHeadingError += headingErrorDegrees * loopTimeSecs
+			}
+			if iHeadingError > gains.MaxIntegral {
+				iHeadingError = gains.MaxIntegral
+			} else if iHeadingError < -gains.MaxIntegral {
+				iHeadingError = -gains.MaxIntegral
+			}
+		} else {
+			iHeadingError = 0
+		}
+
+		// Calculate how fast we want the bot as a whole to rotate.
+		desiredBotDegreesPS := feedforwardDegreesPS + gains.Kp*headingErrorDegrees + gains.Ki*iHeadingError + gains.Kd*dHeadingError
+		lastYawRate = 0
+		if mode == YawRateMode {
+			lastYawRate = controls.yawRateDegreesPerS
+			if math.Abs(controls.yawRateDegreesPerS) > 30 {
+				// Turning fast; the stick knows best.
+				desiredBotDegreesPS = controls.yawRateDegreesPerS
+			}
+		}
+
+		rotationMMPerS := desiredBotDegreesPS * h.Kinematics.TurningCircleMM / 360
+		if rotationMMPerS > limits.MaxRotationMMPerS {
+			rotationMMPerS = limits.MaxRotationMMPerS
+		} else if rotationMMPerS < -limits.MaxRotationMMPerS {
+			rotationMMPerS = -limits.MaxRotationMMPerS
+		}
+
+		if time.Since(lastPrint) > 300*time.Millisecond {
+			fmt.Printf("HH: %v Heading: %.1f Target: %.1f Ref: %.1f Error: %.1f Int: %.1f D: %.1f -> %.3f\n",
+				loopTime, headingEstimate, targetHeading, reference, headingErrorDegrees, iHeadingError, dHeadingError, rotationMMPerS)
+		}
+		targetThrottle, targetTranslation := limits.limitSpeed(controls.throttleMMPerS, controls.translationMMPerS)
+		filteredThrottle := throttleSlew.step(targetThrottle, limits, loopTimeSecs)
+		filteredTranslation := translationSlew.step(targetTranslation, limits, loopTimeSecs)
+
+		// Map the values to speeds for each motor, slowing everything down together if any wheel
+		// would go too fast.
+		wheelRPS, scale := kinematics.Desaturate(h.Kinematics.Wheels(kinematics.Motion{
+			AheadMM:    filteredThrottle,
+			LeftMM:     filteredTranslation,
+			YawDegrees: rotationMMPerS * 360 / h.Kinematics.TurningCircleMM,
+		}), limits.MaxWheelRPS)
+
+		if time.Since(lastPrint) > 300*time.Millisecond {
+			fmt.Printf("RPS: %.2f scale=%.2f\n", wheelRPS, scale)
+			lastPrint = time.Now()
+		}
+		fl, fr, bl, br := kinematics.MotorSpeeds(wheelRPS)
+
+		if err := h.Motors.SetMotorSpeeds(fl, fr, bl, br); err != nil {
+			fmt.Println("Failed to set motor speeds:", err)
+		}
+		lastHeadingError = headingErrorDegrees
+	}
+}
@@ -0,0 +1,47 @@
+package headingholder
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestControllerHandsOffBetweenModes(t *testing.T) {
+	hh, _, ctx := startSimController(t)
+
+	// Spin by yaw rate, at 100 degrees/s, then hand off to a profiled turn mid-spin.  The turn should
+	// pick up at the rate we were already turning rather than pulling up first.
+	hh.SetYawAndThrottle(0.2, 0, 0)
+	time.Sleep(500 * time.Millisecond)
+	if mode := hh.Mode(); mode != YawRateMode {
+		t.Fatalf("Expected to be in yaw-rate mode, got %v", mode)
+	}
+	const target = 170
+	hh.SetHeadingWithProfile(target, 120, 360)
+	last := hh.CurrentHeading().Float()
+	for {
+		time.Sleep(50 * time.Millisecond)
+		heading := hh.CurrentHeading().Float()
+		if target-heading < 25 {
+			break
+		}
+		if rate := (heading - last) / 0.05; rate < 60 {
+			t.Fatalf("Turn slowed to %.0f degrees/s at %.1f degrees after the hand-off", rate, heading)
+		}
+		last = heading
+	}
+	if result := hh.WaitSettled(ctx, 1, 100*time.Millisecond, 3*time.Second); result.Outcome != Settled {
+		t.Fatalf("Turn didn't settle after the hand-off: %v", result)
+	}
+
+	// Now start turning back and hand off to yaw-rate control part way round.  The bot should stop
+	// close to where it had got to rather than carrying on to the turn's target.
+	hh.SetHeadingWithProfile(90, 120, 360)
+	time.Sleep(300 * time.Millisecond)
+	hh.SetYawAndThrottle(0, 0, 0)
+	handOff := hh.CurrentHeading().Float()
+	time.Sleep(time.Second)
+	if heading := hh.CurrentHeading().Float(); math.Abs(heading-handOff) > 5 {
+		t.Errorf("Heading went from %.1f to %.1f after handing off to yaw-rate control", handOff, heading)
+	}
+}
@@ -0,0 +1,25 @@
+package headingholder
+
+import "fmt"
+
+// Gains are the heading PID gains.  The error is in degrees and the output is the rate, in degrees
+// per second, at which to turn the bot.
+type Gains struct {
+	Kp, Ki, Kd float64
+	// MaxIntegral caps the integral term (in degree seconds) and MaxD the derivative (in degrees per
+	// second).
+	MaxIntegral float64
+	MaxD        float64
+}
+
+func DefaultAbsoluteGains() Gains {
+	return Gains{Kp: 6.0, Ki: 0.8, Kd: 0.20, MaxIntegral: 20, MaxD: 100}
+}
+
+func DefaultRelativeGains() Gains {
+	return Gains{Kp: 6.0, Ki: 0.8, Kd: 0.10, MaxIntegral: 20, MaxD: 100}
+}
+
+func (g Gains) String() string {
+	return fmt.Sprintf("kp=%.3f ki=%.3f kd=%.3f maxI=%.1f maxD=%.1f", g.Kp, g.Ki, g.Kd, g.MaxIntegral, g.MaxD)
+}
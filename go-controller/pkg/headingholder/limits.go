@@ -0,0 +1,88 @@
+package headingholder
+
+import "math"
+
+// MotionLimits bound how hard the Controller drives the bot.  A zero MaxSpeedMMPerS,
+// MaxJerkMMPerS3 or MaxBatteryAmps means no limit; the others must be set.
+type MotionLimits struct {
+	// MaxSpeedMMPerS caps the combined throttle and translation.
+	MaxSpeedMMPerS float64
+	// MaxAccelMMPerS2 and MaxJerkMMPerS3 limit how quickly the throttle and translation follow their
+	// setpoints.
+	MaxAccelMMPerS2 float64
+	MaxJerkMMPerS3  float64
+	// MaxRotationMMPerS caps the wheel speed that the heading holder uses to turn the bot.
+	MaxRotationMMPerS float64
+	// MaxWheelRPS caps the speed of any one wheel; all the wheels are slowed together to stay under
+	// it.
+	MaxWheelRPS float64
+	// MaxBatteryAmps is the traction battery current ceiling.  It's enforced by the hardware, using
+	// the power monitor.
+	MaxBatteryAmps float64
+}
+
+// RCLimits are the limits for driving by yaw rate unless told otherwise: quick to respond for a
+// human driver.
+func RCLimits() MotionLimits {
+	return MotionLimits{
+		MaxAccelMMPerS2:   5000,
+		MaxRotationMMPerS: 2000,
+		MaxWheelRPS:       10,
+	}
+}
+
+// CarefulLimits are the limits for holding a heading unless told otherwise: gentle enough that the
+// wheels don't slip and spoil the odometry.  The Controller starts with them.
+func CarefulLimits() MotionLimits {
+	return MotionLimits{
+		MaxAccelMMPerS2:   2000,
+		MaxRotationMMPerS: 400,
+		MaxWheelRPS:       5,
+	}
+}
+
+// limitSpeed scales the throttle and translation down together to the speed limit.
+func (l MotionLimits) limitSpeed(throttle, translation float64) (float64, float64) {
+	speed := math.Hypot(throttle, translation)
+	if l.MaxSpeedMMPerS <= 0 || speed <= l.MaxSpeedMMPerS {
+		return throttle, translation
+	}
+	scale := l.MaxSpeedMMPerS / speed
+	return throttle * scale, translation * scale
+}
+
+// slewLimiter moves a value towards its target subject to the acceleration and jerk limits.
+type slewLimiter struct {
+	value float64
+	rate  float64
+}
+
+func (s *slewLimiter) step(target float64, limits MotionLimits, secs float64) float64 {
+	if secs <= 0 {
+		return s.value
+	}
+	diff := target - s.value
+	maxDelta := limits.MaxAccelMMPerS2 * secs
+	if limits.MaxJerkMMPerS3 <= 0 {
+		old := s.value
+		if diff > maxDelta {
+			s.value += maxDelta
+		} else if diff < -maxDelta {
+			s.value -= maxDelta
+		} else {
+			s.value = target
+		}
+		s.rate = (s.value - old) / secs
+		return s.value
+	}
+
+	// Aim for the rate that gets us to the target in one step, within the acceleration limit, but
+	// leave room to ramp the rate back down to 0 by the time we get there.
+	maxAccel := limits.MaxAccelMMPerS2
+	stopping := math.Sqrt(2 * limits.MaxJerkMMPerS3 * math.Abs(diff))
+	want := max(-maxAccel, -stopping, min(maxAccel, stopping, diff/secs))
+	maxChange := limits.MaxJerkMMPerS3 * secs
+	s.rate += max(-maxChange, min(maxChange, want-s.rate))
+	s.value += s.rate * secs
+	return s.value
+}
@@ -0,0 +1,53 @@
+package headingholder
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSlewWithoutJerkLimit(t *testing.T) {
+	limits := CarefulLimits()
+	var s slewLimiter
+	// 2000mm/s^2 for 10ms steps: 20mm/s per step.
+	for i := 1; i <= 10; i++ {
+		if v := s.step(500, limits, 0.01); math.Abs(v-float64(i)*20) > 1e-9 {
+			t.Fatalf("Step %d: expected %v, got %v", i, float64(i)*20, v)
+		}
+	}
+	// Reaches small targets exactly.
+	if v := s.step(205, limits, 0.01); v != 205 {
+		t.Fatalf("Expected to reach the target, got %v", v)
+	}
+}
+
+func TestSlewWithJerkLimit(t *testing.T) {
+	limits := CarefulLimits()
+	limits.MaxJerkMMPerS3 = 10000
+	var s slewLimiter
+
+	lastRate := 0.0
+	for i := 0; i < 300; i++ {
+		s.step(500, limits, 0.01)
+		if s.rate > limits.MaxAccelMMPerS2+1e-9 {
+			t.Fatalf("Step %d: acceleration %v over the limit", i, s.rate)
+		}
+		if math.Abs(s.rate-lastRate) > limits.MaxJerkMMPerS3*0.01+1e-9 {
+			t.Fatalf("Step %d: acceleration jumped from %v to %v", i, lastRate, s.rate)
+		}
+		lastRate = s.rate
+	}
+	if math.Abs(s.value-500) > 1 || math.Abs(s.rate) > 1 {
+		t.Fatalf("Expected to settle at 500, got %v at rate %v", s.value, s.rate)
+	}
+}
+
+func TestLimitSpeed(t *testing.T) {
+	limits := MotionLimits{MaxSpeedMMPerS: 500}
+	th, tr := limits.limitSpeed(600, 800)
+	if math.Abs(th-300) > 1e-9 || math.Abs(tr-400) > 1e-9 {
+		t.Fatalf("Expected (300, 400), got (%v, %v)", th, tr)
+	}
+	if th, tr := (MotionLimits{}).limitSpeed(600, 800); th != 600 || tr != 800 {
+		t.Fatalf("Zero limit shouldn't limit, got (%v, %v)", th, tr)
+	}
+}
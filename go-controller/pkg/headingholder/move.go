@@ -0,0 +1,161 @@
+package headingholder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
+)
+
+// Odometer is implemented by motor controllers that count wheel rotations.  The Controller uses it to
+// track the bot's position for MoveBy and MoveTo.
+type Odometer interface {
+	AccumulatedRotations() picobldc.PerMotorVal[float64]
+}
+
+var ErrNoOdometer = errors.New("heading holder: motors don't report wheel rotations")
+
+// ErrLoopStopped is returned by MoveTo if the loop stops before the move finishes.
+var ErrLoopStopped = errors.New("heading holder: loop stopped")
+
+// MoveProfile is the trapezoidal velocity profile that MoveBy and MoveTo follow: accelerate at
+// AccelMMPerS2 up to MaxSpeedMMPerS, then slow down at the same rate so as to arrive at the target.
+// MinSpeedMMPerS stops the bot from creeping for ever at the end of a move.
+type MoveProfile struct {
+	MaxSpeedMMPerS float64
+	AccelMMPerS2   float64
+	MinSpeedMMPerS float64
+	ToleranceMM    float64
+}
+
+func DefaultMoveProfile() MoveProfile {
+	return MoveProfile{
+		MaxSpeedMMPerS: 400,
+		AccelMMPerS2:   800,
+		MinSpeedMMPerS: 40,
+		ToleranceMM:    5,
+	}
+}
+
+// maxMoveStepSecs caps the time step used to accelerate.  The first reading of a move may be from
+// before it started; that time doesn't count.
+const maxMoveStepSecs = 0.05
+
+// Position returns the bot's position according to the wheel rotations, in mm.  X is in the
+// direction of heading 0 and Y is to its left; the origin is wherever the loop started.
+func (h *Controller) Position() (x, y float64) {
+	h.controlLock.Lock()
+	defer h.controlLock.Unlock()
+
+	return h.x, h.y
+}
+
+func (h *Controller) SetMoveProfile(p MoveProfile) {
+	h.controlLock.Lock()
+	defer h.controlLock.Unlock()
+
+	h.moveProfile = p
+}
+
+// MoveBy moves the bot aheadMM forwards and leftMM to the left, relative to the way that it is facing
+// when the move starts, and then stops.  The heading is held as usual.  It returns the displacement
+// that was achieved, which is short of the target if the context is cancelled.
+func (h *Controller) MoveBy(ctx context.Context, aheadMM, leftMM float64) (achievedAheadMM, achievedLeftMM float64, err error) {
+	h.controlLock.Lock()
+	startX, startY := h.x, h.y
+	heading := h.currentHeading.Float() * math.Pi / 180
+	h.controlLock.Unlock()
+
+	sin, cos := math.Sin(heading), math.Cos(heading)
+	targetX := startX + aheadMM*cos - leftMM*sin
+	targetY := startY + aheadMM*sin + leftMM*cos
+
+	x, y, err := h.MoveTo(ctx, targetX, targetY)
+	dx, dy := x-startX, y-startY
+	return dx*cos + dy*sin, -dx*sin + dy*cos, err
+}
+
+// MoveTo moves the bot to the given point, in the same coordinates as Position, and then stops,
+// steering to correct for any drift on the way.  It returns the position that was reached.
+func (h *Controller) MoveTo(ctx context.Context, targetX, targetY float64) (x, y float64, err error) {
+	if _, ok := h.Motors.(Odometer); !ok {
+		return 0, 0, ErrNoOdometer
+	}
+
+	h.controlLock.Lock()
+	profile := h.moveProfile
+	startX, startY := h.x, h.y
+	h.controlLock.Unlock()
+	if profile == (MoveProfile{}) {
+		profile = DefaultMoveProfile()
+	}
+	fmt.Printf("HH: moving from (%.0f, %.0f) to (%.0f, %.0f)\n", startX, startY, targetX, targetY)
+
+	// If we overshoot, the remaining displacement points back the way we came; stop rather than
+	// reversing.
+	initialDX, initialDY := targetX-startX, targetY-startY
+
+	defer h.SetThrottle(0)
+	// Wake up the wait for a reading below if we're cancelled, in case the readings have stopped.
+	stop := context.AfterFunc(ctx, func() {
+		h.controlLock.Lock()
+		h.onNewReading.Broadcast()
+		h.controlLock.Unlock()
+	})
+	defer stop()
+
+	var speed float64
+	lastTime := h.readingTime()
+	for {
+		h.controlLock.Lock()
+		if ctx.Err() != nil || h.loopStopped {
+			x, y = h.x, h.y
+			h.controlLock.Unlock()
+			if ctx.Err() != nil {
+				return x, y, ctx.Err()
+			}
+			return x, y, ErrLoopStopped
+		}
+		h.onNewReading.Wait()
+		if ctx.Err() != nil || h.loopStopped || h.lastReadingTime == lastTime {
+			// Woken without a new reading; check why at the top of the loop.
+			h.controlLock.Unlock()
+			continue
+		}
+		x, y = h.x, h.y
+		heading := h.currentHeading.Float()
+		now := h.lastReadingTime
+		h.controlLock.Unlock()
+
+		dx, dy := targetX-x, targetY-y
+		remaining := math.Hypot(dx, dy)
+		if remaining <= profile.ToleranceMM || dx*initialDX+dy*initialDY <= 0 {
+			fmt.Printf("HH: move finished at (%.0f, %.0f), %.1fmm from target\n", x, y, remaining)
+			return x, y, nil
+		}
+
+		// Trapezoidal profile: limited by the acceleration from our current speed and by the
+		// speed from which we can still stop in the remaining distance.
+		dt := min(now.Sub(lastTime).Seconds(), maxMoveStepSecs)
+		lastTime = now
+		speed = min(
+			profile.MaxSpeedMMPerS,
+			speed+profile.AccelMMPerS2*dt,
+			math.Sqrt(2*profile.AccelMMPerS2*remaining),
+		)
+		speed = max(speed, profile.MinSpeedMMPerS)
+
+		// SetThrottleWithAngle takes the angle relative to the way the bot is facing.
+		worldAngle := math.Atan2(dy, dx) * 180 / math.Pi
+		h.SetThrottleWithAngle(speed, worldAngle-heading)
+	}
+}
+
+func (h *Controller) readingTime() time.Time {
+	h.controlLock.Lock()
+	defer h.controlLock.Unlock()
+	return h.lastReadingTime
+}
@@ -0,0 +1,103 @@
+package headingholder
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/simbot"
+)
+
+// simMotors adapts the simulated robot to RawControl and Odometer.
+type simMotors struct {
+	robot *simbot.Robot
+}
+
+func (m simMotors) SetMotorSpeeds(frontLeft, frontRight, backLeft, backRight int16) error {
+	m.robot.SetMotorSpeeds(frontLeft, frontRight, backLeft, backRight)
+	return nil
+}
+
+func (m simMotors) AccumulatedRotations() picobldc.PerMotorVal[float64] {
+	return m.robot.AccumulatedRotations()
+}
+
+// startSimController runs a Controller that drives a simulated robot until the test finishes.  The
+// returned context times out if the test runs too long.
+func startSimController(t *testing.T) (*Controller, *simbot.Robot, context.Context) {
+	// The loop blocks waiting for IMU reports so the robot has to outlive it.
+	robotCtx, cancelRobot := context.WithCancel(context.Background())
+	robot := simbot.New(simbot.DefaultArena())
+	go robot.Run(robotCtx)
+
+	ctx, cancel := context.WithTimeout(robotCtx, 20*time.Second)
+	hh := NewController(simMotors{robot})
+	hh.IMU = robot.IMU()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go hh.Loop(ctx, &wg)
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+		cancelRobot()
+	})
+	return hh, robot, ctx
+}
+
+func TestMoveBy(t *testing.T) {
+	hh, robot, ctx := startSimController(t)
+
+	for _, move := range []struct{ ahead, left float64 }{
+		{400, 0},
+		{0, -300},
+		{-200, 200},
+	} {
+		startX, startY, _ := robot.Pose()
+		ahead, left, err := hh.MoveBy(ctx, move.ahead, move.left)
+		if err != nil {
+			t.Fatalf("MoveBy(%v, %v) failed: %v", move.ahead, move.left, err)
+		}
+		if math.Hypot(ahead-move.ahead, left-move.left) > 20 {
+			t.Errorf("MoveBy(%v, %v) reported (%.1f, %.1f)", move.ahead, move.left, ahead, left)
+		}
+
+		// Let the bot come to a stop before checking where it really got to.
+		time.Sleep(300 * time.Millisecond)
+		x, y, _ := robot.Pose()
+		moved := math.Hypot(x-startX, y-startY)
+		if want := math.Hypot(move.ahead, move.left); math.Abs(moved-want) > 30 {
+			t.Errorf("MoveBy(%v, %v) moved the bot %.1fmm, expected %.1fmm", move.ahead, move.left, moved, want)
+		}
+	}
+}
+
+func TestMoveToWithoutOdometer(t *testing.T) {
+	hh := NewController(nil)
+	if _, _, err := hh.MoveTo(context.Background(), 100, 0); err != ErrNoOdometer {
+		t.Fatalf("Expected ErrNoOdometer, got %v", err)
+	}
+}
+
+func TestMoveToGivesUpWithoutReadings(t *testing.T) {
+	// No loop is running so there are no readings to wait for.
+	hh := NewController(simMotors{simbot.New(simbot.DefaultArena())})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, _, err := hh.MoveTo(ctx, 100, 0); err != context.Canceled {
+		t.Fatalf("Expected the move to be cancelled, got %v", err)
+	}
+
+	time.AfterFunc(20*time.Millisecond, func() {
+		// As the loop does when it exits.
+		hh.controlLock.Lock()
+		hh.loopStopped = true
+		hh.onNewReading.Broadcast()
+		hh.controlLock.Unlock()
+	})
+	if _, _, err := hh.MoveTo(context.Background(), 100, 0); err != ErrLoopStopped {
+		t.Fatalf("Expected ErrLoopStopped, got %v", err)
+	}
+}
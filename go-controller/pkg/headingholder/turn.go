@@ -0,0 +1,51 @@
+package headingholder
+
+import (
+	"math"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder/angle"
+)
+
+// TurnProfile limits how quickly the reference heading moves during a profiled turn; see
+// SetHeadingWithProfile.
+type TurnProfile struct {
+	MaxRateDegreesPerS   float64
+	MaxAccelDegreesPerS2 float64
+}
+
+// motorLagSecs is roughly how long the motors take to reach a new speed.  The trajectory feeds
+// forward its acceleration scaled by this so that the bot doesn't fall behind the reference as it
+// speeds up, or run ahead of it and overshoot as it slows down.
+const motorLagSecs = 0.06
+
+// turnTrajectory moves a reference heading towards the target along a trapezoidal rate profile:
+// accelerate up to the maximum rate, then slow down so as to stop at the target.
+type turnTrajectory struct {
+	profile   TurnProfile
+	reference angle.PlusMinus180
+	rate      float64
+}
+
+// step advances the reference by secs and returns it along with the turn rate, in degrees/s, to feed
+// forward.  done is true once the reference has reached the target.
+func (t *turnTrajectory) step(target angle.PlusMinus180, secs float64) (reference angle.PlusMinus180, feedforward float64, done bool) {
+	remaining := target.Sub(t.reference).Float()
+	maxRate := t.profile.MaxRateDegreesPerS
+	accel := t.profile.MaxAccelDegreesPerS2
+
+	// The fastest we can go and still stop at the target, in the direction of the target.
+	want := math.Copysign(min(maxRate, math.Sqrt(2*accel*math.Abs(remaining))), remaining)
+	maxChange := accel * secs
+	change := max(-maxChange, min(maxChange, want-t.rate))
+	t.rate += change
+
+	move := t.rate * secs
+	if remaining*move >= remaining*remaining || math.Abs(remaining) <= maxChange*secs {
+		// We'd get there (or past) this step, or we're close enough that the PID can do the rest.
+		t.reference = target
+		t.rate = 0
+		return t.reference, 0, true
+	}
+	t.reference = t.reference.AddFloat(move)
+	return t.reference, t.rate + motorLagSecs*change/secs, false
+}
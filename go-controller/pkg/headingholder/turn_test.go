@@ -0,0 +1,92 @@
+package headingholder
+
+import (
+	"context"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder/angle"
+)
+
+func TestTurnTrajectory(t *testing.T) {
+	for _, tc := range []struct{ from, to, turn float64 }{
+		{0, 90, 90},
+		{170, -170, 20},
+		{-30, -150, -120},
+	} {
+		turn := turnTrajectory{
+			profile:   TurnProfile{MaxRateDegreesPerS: 180, MaxAccelDegreesPerS2: 360},
+			reference: angle.FromFloat(tc.from),
+		}
+		const secs = 0.01
+		turned, lastRate := 0.0, 0.0
+		var steps int
+		for done := false; !done; steps++ {
+			if steps > 1000 {
+				t.Fatalf("Turn from %v to %v never finished", tc.from, tc.to)
+			}
+			last := turn.reference
+			var reference angle.PlusMinus180
+			reference, _, done = turn.step(angle.FromFloat(tc.to), secs)
+			rate := turn.rate
+			turned += reference.Sub(last).Float()
+			if math.Abs(rate) > 180 || math.Abs(rate-lastRate) > 360*secs+1e-9 && !done {
+				t.Fatalf("Turn from %v to %v broke the profile: rate %v after %v", tc.from, tc.to, rate, lastRate)
+			}
+			if math.Abs(turned) > math.Abs(tc.turn)+1e-9 || turned*tc.turn < 0 {
+				t.Fatalf("Turn from %v to %v went the wrong way or overshot: %v", tc.from, tc.to, turned)
+			}
+			lastRate = rate
+		}
+		if math.Abs(turned-tc.turn) > 1e-9 {
+			t.Errorf("Turn from %v to %v turned %v", tc.from, tc.to, turned)
+		}
+		// Accelerating then decelerating at 360 degrees/s² takes 2*sqrt(turn/360) seconds.
+		expected := 2 * math.Sqrt(math.Abs(tc.turn)/360)
+		if took := float64(steps) * secs; math.Abs(took-expected) > 0.1 {
+			t.Errorf("Turn from %v to %v took %.2fs, expected %.2fs", tc.from, tc.to, took, expected)
+		}
+	}
+}
+
+func TestSetHeadingWithProfile(t *testing.T) {
+	hh, _, ctx := startSimController(t)
+
+	// Watch for overshoot while we wait for the turn.
+	var maxHeading float64
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	var watchWG sync.WaitGroup
+	watchWG.Add(1)
+	go func() {
+		defer watchWG.Done()
+		for watchCtx.Err() == nil {
+			maxHeading = max(maxHeading, hh.CurrentHeading().Float())
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	start := time.Now()
+	hh.SetHeadingWithProfile(120, 120, 360)
+	result := hh.WaitSettled(ctx, 1, 100*time.Millisecond, 5*time.Second)
+	took := time.Since(start)
+	stopWatching()
+	watchWG.Wait()
+	if result.Outcome != Settled {
+		t.Fatalf("Turn didn't settle: %v", result)
+	}
+	residual := result.ResidualDegrees
+
+	// The reference takes 1/3s to speed up to 120 degrees/s and 1/3s to slow down, covering 20
+	// degrees each time, and 2/3s for the 80 degrees in between: 1.33s.
+	if took < 1300*time.Millisecond {
+		t.Errorf("WaitSettled returned after %v, before the turn could have finished", took)
+	}
+	if math.Abs(residual) > 1 {
+		t.Errorf("Turn finished %.1f degrees from the target", residual)
+	}
+	if maxHeading > 121 {
+		t.Errorf("Turn overshot to %.1f degrees", maxHeading)
+	}
+}
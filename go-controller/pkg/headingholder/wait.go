@@ -0,0 +1,125 @@
+package headingholder
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// SettleOutcome says how WaitSettled finished.
+type SettleOutcome int
+
+const (
+	// Settled means that the heading stayed within the tolerance of the target for the settle time.
+	Settled SettleOutcome = iota
+	// TimedOut means that the heading didn't settle in time; the bot may still be turning.
+	TimedOut
+	// Oscillating means that the heading kept swinging past the target, so it's not likely to settle.
+	Oscillating
+	// Cancelled means that the context was cancelled.
+	Cancelled
+)
+
+func (o SettleOutcome) String() string {
+	switch o {
+	case Settled:
+		return "settled"
+	case TimedOut:
+		return "timed out"
+	case Oscillating:
+		return "oscillating"
+	case Cancelled:
+		return "cancelled"
+	}
+	return fmt.Sprintf("SettleOutcome(%d)", int(o))
+}
+
+type SettleResult struct {
+	Outcome SettleOutcome
+	// ResidualDegrees is the target heading minus the heading, when WaitSettled returned.
+	ResidualDegrees float64
+	Took            time.Duration
+}
+
+func (r SettleResult) String() string {
+	return fmt.Sprintf("%v with %.2f degrees residual after %v", r.Outcome, r.ResidualDegrees, r.Took)
+}
+
+// settleOscillationSwings is how many times the heading can swing from one side of the tolerance band
+// to the other before WaitSettled gives up on it.
+const settleOscillationSwings = 4
+
+// WaitSettled waits for the heading to reach the target and stay within toleranceDegrees of it for
+// settleTime, including waiting for any profiled turn to finish.  It gives up after timeout, if the
+// heading oscillates about the target, or if the context is cancelled.  It returns even if the loop
+// has stopped.
+func (h *Controller) WaitSettled(ctx context.Context, toleranceDegrees float64, settleTime, timeout time.Duration) SettleResult {
+	start := time.Now()
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	// Wake up the wait for a reading below when we're done waiting, in case the readings have stopped.
+	stop := context.AfterFunc(waitCtx, func() {
+		h.controlLock.Lock()
+		h.onNewReading.Broadcast()
+		h.controlLock.Unlock()
+	})
+	defer stop()
+
+	h.controlLock.Lock()
+	defer h.controlLock.Unlock()
+
+	lastReading := h.lastReadingTime
+	var inToleranceSince time.Time
+	var side, swings int
+	for {
+		result := SettleResult{
+			ResidualDegrees: h.targetHeading.Sub(h.currentHeading).Float(),
+			Took:            time.Since(start),
+		}
+		if waitCtx.Err() != nil {
+			result.Outcome = TimedOut
+			if ctx.Err() != nil {
+				result.Outcome = Cancelled
+			}
+			return result
+		}
+
+		// Only judge readings taken since we started; the current heading may be from before the
+		// target changed.
+		if h.lastReadingTime != lastReading {
+			lastReading = h.lastReadingTime
+			residual := result.ResidualDegrees
+
+			// Settle time is measured in IMU time so that replays behave the same.
+			if math.Abs(residual) <= toleranceDegrees && !h.turning {
+				if inToleranceSince.IsZero() {
+					inToleranceSince = lastReading
+				}
+				if lastReading.Sub(inToleranceSince) >= settleTime {
+					result.Outcome = Settled
+					return result
+				}
+			} else {
+				inToleranceSince = time.Time{}
+			}
+
+			newSide := side
+			if residual > toleranceDegrees {
+				newSide = 1
+			} else if residual < -toleranceDegrees {
+				newSide = -1
+			}
+			if side != 0 && newSide != side {
+				swings++
+				if swings >= settleOscillationSwings {
+					result.Outcome = Oscillating
+					return result
+				}
+			}
+			side = newSide
+		}
+
+		h.onNewReading.Wait()
+	}
+}
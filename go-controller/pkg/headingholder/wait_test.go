@@ -0,0 +1,70 @@
+package headingholder
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder/angle"
+)
+
+// feedHeadings stands in for the loop, publishing a reading every 10ms of IMU time until the
+// headings run out.
+func feedHeadings(h *Controller, headings []float64) {
+	now := time.Unix(1000, 0)
+	for _, heading := range headings {
+		time.Sleep(time.Millisecond)
+		now = now.Add(10 * time.Millisecond)
+		h.controlLock.Lock()
+		h.currentHeading = angle.FromFloat(heading)
+		h.lastReadingTime = now
+		h.onNewReading.Broadcast()
+		h.controlLock.Unlock()
+	}
+}
+
+func TestWaitSettled(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		headings []float64
+		outcome  SettleOutcome
+	}{
+		{"settles", []float64{80, 85, 89, 89.5, 90, 90.2, 90.1, 90, 90, 90, 90, 90, 90, 90, 90, 90, 90, 90}, Settled},
+		{"brief visit", []float64{80, 89.5, 92, 95, 95, 95, 95, 95, 95, 95, 95, 95, 95, 95, 95, 95}, TimedOut},
+		{"oscillates", []float64{80, 95, 85, 95, 85, 95, 85, 95, 85, 95}, Oscillating},
+		{"no readings", nil, TimedOut},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			hh := NewController(nil)
+			hh.SetHeading(90)
+			go feedHeadings(hh, tc.headings)
+			result := hh.WaitSettled(context.Background(), 1, 100*time.Millisecond, 300*time.Millisecond)
+			if result.Outcome != tc.outcome {
+				t.Fatalf("Expected %v, got %v", tc.outcome, result)
+			}
+			if result.Took > time.Second {
+				t.Errorf("Took too long: %v", result)
+			}
+		})
+	}
+}
+
+func TestWaitSettledCancelled(t *testing.T) {
+	hh := NewController(nil)
+	hh.SetHeading(90)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if result := hh.WaitSettled(ctx, 1, 100*time.Millisecond, time.Minute); result.Outcome != Cancelled {
+		t.Fatalf("Expected to be cancelled, got %v", result)
+	}
+}
+
+func TestWaitSettledWaitsForProfiledTurn(t *testing.T) {
+	hh := NewController(nil)
+	hh.SetHeadingWithProfile(90, 120, 360)
+	// Already at the target but the turn's reference hasn't got there yet.
+	go feedHeadings(hh, []float64{90, 90, 90, 90, 90, 90, 90, 90, 90, 90, 90, 90, 90, 90, 90})
+	if result := hh.WaitSettled(context.Background(), 1, 50*time.Millisecond, 200*time.Millisecond); result.Outcome != TimedOut {
+		t.Fatalf("Expected to wait for the turn, got %v", result)
+	}
+}
@@ -0,0 +1,230 @@
+package i2cbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"syscall"
+
+	"golang.org/x/exp/io/i2c/driver"
+)
+
+// The errors that the fake bus returns, chosen to match what the kernel's I2C driver returns on the Pi.
+var (
+	ErrNoDevice  error = syscall.ENXIO     // Nothing acknowledged the address.
+	ErrBusStuck  error = syscall.ETIMEDOUT // A device is holding the bus.
+	ErrTransient error = syscall.EIO       // A one-off glitch, as seen when the motors are noisy.
+
+	errClosed = errors.New("i2cbus: device closed")
+)
+
+// Model is the behaviour of a device attached to a Fake bus.  Tx has the same semantics as
+// driver.Conn.Tx: it writes w (if not empty) and then reads len(r) bytes into r.
+type Model interface {
+	Tx(w, r []byte) error
+}
+
+// Fake is an in-memory I2C bus for tests.  Devices are attached at an address; transactions with an
+// address that has nothing attached fail with ErrNoDevice.  Faults can be injected per device or for
+// the whole bus.
+type Fake struct {
+	lock    sync.Mutex
+	devices map[int]*fakeSlot
+	stuck   bool
+}
+
+type fakeSlot struct {
+	model  Model
+	faults []error
+}
+
+var _ Bus = (*Fake)(nil)
+
+func NewFake() *Fake {
+	return &Fake{devices: map[int]*fakeSlot{}}
+}
+
+// Attach puts a device on the bus, replacing any that was already at that address.
+func (f *Fake) Attach(addr int, m Model) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.devices[addr] = &fakeSlot{model: m}
+}
+
+// Detach removes a device from the bus, as if it had been unplugged.
+func (f *Fake) Detach(addr int) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	delete(f.devices, addr)
+}
+
+// InjectFaults makes the next len(errs) transactions with the device at addr fail with the given
+// errors, in order.  Failed transactions don't reach the device.
+func (f *Fake) InjectFaults(addr int, errs ...error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if s := f.devices[addr]; s != nil {
+		s.faults = append(s.faults, errs...)
+	}
+}
+
+// SetStuck makes every transaction fail with ErrBusStuck until it is called again with false.
+func (f *Fake) SetStuck(stuck bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.stuck = stuck
+}
+
+// Open always succeeds, as it does for /dev/i2c-N; problems only show up when talking to the device.
+func (f *Fake) Open(addr int, tenbit bool) (driver.Conn, error) {
+	return &fakeConn{bus: f, addr: addr}, nil
+}
+
+func (f *Fake) tx(addr int, w, r []byte) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.stuck {
+		return ErrBusStuck
+	}
+	s := f.devices[addr]
+	if s == nil {
+		return ErrNoDevice
+	}
+	if len(s.faults) > 0 {
+		err := s.faults[0]
+		s.faults = s.faults[1:]
+		return err
+	}
+	return s.model.Tx(w, r)
+}
+
+type fakeConn struct {
+	bus    *Fake
+	addr   int
+	closed bool
+}
+
+func (c *fakeConn) Tx(w, r []byte) error {
+	if c.closed {
+		return errClosed
+	}
+	return c.bus.tx(c.addr, w, r)
+}
+
+func (c *fakeConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+// RegWrite is a write to one register, as recorded by Registers.
+type RegWrite struct {
+	Reg   byte
+	Value []byte
+}
+
+// Registers models a typical register-based device.  The first byte of a write sets the register
+// pointer and any following bytes are stored from there; a read returns bytes from the pointer.  The
+// pointer auto-increments a register at a time.  A write of just the register byte only moves the
+// pointer; for devices without registers, such as the mux, that byte is the device's control value so
+// it is recorded as a write with no value.
+type Registers struct {
+	width int
+
+	lock    sync.Mutex
+	pointer byte
+	values  map[byte][]byte
+	writes  []RegWrite
+	onWrite func(RegWrite)
+}
+
+var _ Model = (*Registers)(nil)
+
+// NewRegisters returns a register file with width-byte registers, all initially zero.
+func NewRegisters(width int) *Registers {
+	return &Registers{
+		width:  width,
+		values: map[byte][]byte{},
+	}
+}
+
+// OnWrite sets a function that is called after each register write, for emulating side effects.  It
+// is called without the register lock held, so it may use Get and Set.
+func (r *Registers) OnWrite(f func(RegWrite)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.onWrite = f
+}
+
+func (r *Registers) Tx(w, rd []byte) error {
+	var writes []RegWrite
+	r.lock.Lock()
+	if len(w) > 0 {
+		r.pointer = w[0]
+		data := w[1:]
+		if len(data) == 0 && len(rd) == 0 {
+			writes = append(writes, RegWrite{Reg: r.pointer})
+		}
+		for len(data) > 0 {
+			n := min(len(data), r.width)
+			value := r.get(r.pointer)
+			copy(value, data[:n])
+			r.values[r.pointer] = value
+			writes = append(writes, RegWrite{Reg: r.pointer, Value: append([]byte(nil), data[:n]...)})
+			data = data[n:]
+			r.pointer++
+		}
+		r.writes = append(r.writes, writes...)
+	}
+	for off := 0; off < len(rd); off += r.width {
+		copy(rd[off:], r.get(r.pointer))
+		r.pointer++
+	}
+	onWrite := r.onWrite
+	r.lock.Unlock()
+
+	if onWrite != nil {
+		for _, wr := range writes {
+			onWrite(wr)
+		}
+	}
+	return nil
+}
+
+func (r *Registers) get(reg byte) []byte {
+	value := make([]byte, r.width)
+	copy(value, r.values[reg])
+	return value
+}
+
+// Get returns a copy of a register's value.
+func (r *Registers) Get(reg byte) []byte {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.get(reg)
+}
+
+// Set sets a register's value without recording a write.
+func (r *Registers) Set(reg byte, value ...byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	v := make([]byte, r.width)
+	copy(v, value)
+	r.values[reg] = v
+}
+
+// Uint16 returns a 16-bit register's value, which is big-endian on the wire.
+func (r *Registers) Uint16(reg byte) uint16 {
+	return binary.BigEndian.Uint16(r.Get(reg))
+}
+
+func (r *Registers) SetUint16(reg byte, value uint16) {
+	r.Set(reg, byte(value>>8), byte(value))
+}
+
+// Writes returns all the register writes so far, oldest first.
+func (r *Registers) Writes() []RegWrite {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]RegWrite(nil), r.writes...)
+}
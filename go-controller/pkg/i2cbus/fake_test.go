@@ -0,0 +1,63 @@
+package i2cbus
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRegistersAutoIncrement(t *testing.T) {
+	bus := NewFake()
+	regs := NewRegisters(2)
+	bus.Attach(0x10, regs)
+	dev, err := Open(bus, 0x10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dev.WriteReg(3, []byte{0x12, 0x34, 0x56, 0x78}); err != nil {
+		t.Fatal(err)
+	}
+	if regs.Uint16(3) != 0x1234 || regs.Uint16(4) != 0x5678 {
+		t.Fatalf("Unexpected register values %04x %04x", regs.Uint16(3), regs.Uint16(4))
+	}
+	buf := make([]byte, 4)
+	if err := dev.ReadReg(3, buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, []byte{0x12, 0x34, 0x56, 0x78}) {
+		t.Fatalf("Read back %x", buf)
+	}
+	if n := len(regs.Writes()); n != 2 {
+		t.Fatalf("Expected 2 register writes, got %d", n)
+	}
+}
+
+func TestFaults(t *testing.T) {
+	bus := NewFake()
+	bus.Attach(0x10, NewRegisters(1))
+	dev, _ := Open(bus, 0x10)
+	missing, _ := Open(bus, 0x11)
+
+	if err := missing.Write([]byte{0}); err != ErrNoDevice {
+		t.Errorf("Expected ErrNoDevice, got %v", err)
+	}
+
+	bus.InjectFaults(0x10, ErrTransient)
+	if err := dev.Write([]byte{0}); err != ErrTransient {
+		t.Errorf("Expected injected fault, got %v", err)
+	}
+	if err := dev.Write([]byte{0}); err != nil {
+		t.Errorf("Expected fault to clear, got %v", err)
+	}
+
+	bus.SetStuck(true)
+	if err := dev.Write([]byte{0}); err != ErrBusStuck {
+		t.Errorf("Expected ErrBusStuck, got %v", err)
+	}
+	bus.SetStuck(false)
+
+	bus.Detach(0x10)
+	if err := dev.Write([]byte{0}); err != ErrNoDevice {
+		t.Errorf("Expected ErrNoDevice after detach, got %v", err)
+	}
+}
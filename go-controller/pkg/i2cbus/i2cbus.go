@@ -0,0 +1,39 @@
+// Package i2cbus is the I2C bus abstraction used by our device drivers.  On the robot the bus is a
+// /dev/i2c-N device file; in tests it's a Fake with in-memory register maps.
+package i2cbus
+
+import (
+	"golang.org/x/exp/io/i2c"
+	"golang.org/x/exp/io/i2c/driver"
+)
+
+// Bus is an I2C bus that devices can be opened on.
+type Bus = driver.Opener
+
+// Device is an open connection to a device on a Bus.
+type Device interface {
+	// Read reads len(buf) bytes from the device.
+	Read(buf []byte) error
+	// ReadReg is like Read but it reads from a register.
+	ReadReg(reg byte, buf []byte) error
+	// Write writes the buffer to the device; to write to a register, the register should be the first
+	// byte of the buffer.
+	Write(buf []byte) error
+	// WriteReg is like Write but it writes to a register.
+	WriteReg(reg byte, buf []byte) error
+	Close() error
+}
+
+// Devfs returns the bus for a Linux I2C device file such as /dev/i2c-1.
+func Devfs(deviceFile string) Bus {
+	return &i2c.Devfs{Dev: deviceFile}
+}
+
+// Open opens the device at the given address.
+func Open(bus Bus, addr int) (Device, error) {
+	dev, err := i2c.Open(bus, addr)
+	if err != nil {
+		return nil, err
+	}
+	return dev, nil
+}
@@ -3,7 +3,7 @@ package ina219
 import (
 	"fmt"
 
-	"golang.org/x/exp/io/i2c"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/i2cbus"
 )
 
 const (
@@ -39,7 +39,11 @@ type INA219 struct {
 }
 
 func NewI2C(deviceFile string, addr int) (Interface, error) {
-	dev, err := i2c.Open(&i2c.Devfs{deviceFile}, addr)
+	return NewOnBus(i2cbus.Devfs(deviceFile), addr)
+}
+
+func NewOnBus(bus i2cbus.Bus, addr int) (Interface, error) {
+	dev, err := i2cbus.Open(bus, addr)
 	if err != nil {
 		return nil, err
 	}
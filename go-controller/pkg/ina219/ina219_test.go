@@ -0,0 +1,48 @@
+package ina219
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/i2cbus"
+)
+
+func TestConfigureWritesCalibration(t *testing.T) {
+	bus := i2cbus.NewFake()
+	regs := i2cbus.NewRegisters(2)
+	bus.Attach(Addr1, regs)
+	ina, err := NewOnBus(bus, Addr1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Our power monitor: 50mOhm shunt, 5A max.
+	if err := ina.Configure(0.05, 5.0); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+	// 0.04096 / ((5 / 2^15) * 0.05), from the datasheet.
+	if cal := regs.Uint16(RegCalibration); cal != 5368 {
+		t.Fatalf("Calibration register = %d, expected 5368", cal)
+	}
+
+	// Bus voltage is in the top 13 bits.
+	regs.SetUint16(RegBusV, 3000<<3)
+	if v, err := ina.BusVoltage(); err != nil || math.Abs(v-12) > 1e-9 {
+		t.Fatalf("BusVoltage() = %v, %v; expected 12V", v, err)
+	}
+	regs.SetUint16(RegCurrent, 1<<14)
+	if a, err := ina.CurrentAmps(); err != nil || math.Abs(a-2.5) > 1e-9 {
+		t.Fatalf("CurrentAmps() = %v, %v; expected 2.5A", a, err)
+	}
+}
+
+func TestMissingDevice(t *testing.T) {
+	bus := i2cbus.NewFake()
+	ina, err := NewOnBus(bus, Addr1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ina.Configure(0.05, 5.0); err != i2cbus.ErrNoDevice {
+		t.Fatalf("Expected ErrNoDevice, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+// Package kinematics maps between the motion of the mecanum chassis and the speeds of its four
+// wheels.
+//
+// Wheel speeds are positive = anti-clockwise, the same as the Pico-BLDC, so the left and right wheels
+// turn in opposite directions to go straight ahead.  Bot motion is relative to the way the bot is
+// facing: ahead, to the left and yaw anti-clockwise.  Everything is linear so the same functions map
+// wheel rotations to displacements and wheel RPS to velocities.
+package kinematics
+
+import (
+	"math"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/chassis"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
+)
+
+// MecFac corrects for the mecanum wheels moving the bot less far sideways than ahead for the same
+// number of wheel rotations.  Measured on the arena floor.
+const MecFac = 1.044
+
+// Motion is a movement of the bot, or a velocity if the units are taken per second.
+type Motion struct {
+	AheadMM    float64
+	LeftMM     float64
+	YawDegrees float64
+}
+
+// Model describes the chassis.  Use Default() rather than the zero value.
+type Model struct {
+	WheelCircumMM float64
+	// TurningCircleMM is the distance that each wheel travels when the bot turns a full circle.
+	TurningCircleMM float64
+	// SidewaysSlip is how much further the wheels turn when moving sideways than when moving ahead
+	// the same distance.
+	SidewaysSlip float64
+	// WheelScale is each wheel's distance travelled per rotation relative to the nominal
+	// circumference, to allow for worn or mismatched tyres.
+	WheelScale picobldc.PerMotorVal[float64]
+}
+
+func Default() Model {
+	return Model{
+		WheelCircumMM:   chassis.WheelCircumMM,
+		TurningCircleMM: chassis.WheelTurningCircleDiaMM,
+		SidewaysSlip:    MecFac,
+		WheelScale:      picobldc.PerMotorVal[float64]{1, 1, 1, 1},
+	}
+}
+
+// Mix combines throttle, translation and rotation, in wheel units, into the four wheel speeds.
+// Positive rotation turns the bot anti-clockwise.
+func Mix(throttle, translation, rotation float64) (w picobldc.PerMotorVal[float64]) {
+	w[picobldc.FrontLeft] = throttle - rotation - translation
+	w[picobldc.BackLeft] = throttle - rotation + translation
+	w[picobldc.FrontRight] = -throttle - rotation - translation
+	w[picobldc.BackRight] = -throttle - rotation + translation
+	return
+}
+
+// Unmix is the inverse of Mix.  If the wheels disagree, for example because one of them slipped,
+// the result is the least squares fit.
+func Unmix(w picobldc.PerMotorVal[float64]) (throttle, translation, rotation float64) {
+	fl := w[picobldc.FrontLeft]
+	fr := w[picobldc.FrontRight]
+	bl := w[picobldc.BackLeft]
+	br := w[picobldc.BackRight]
+
+	throttle = (fl + bl - fr - br) / 4
+	translation = (bl + br - fl - fr) / 4
+	rotation = -(fl + bl + fr + br) / 4
+	return
+}
+
+// Wheels returns the wheel rotations needed to make the given motion.
+func (m Model) Wheels(motion Motion) picobldc.PerMotorVal[float64] {
+	w := Mix(
+		motion.AheadMM/m.WheelCircumMM,
+		motion.LeftMM*m.SidewaysSlip/m.WheelCircumMM,
+		motion.YawDegrees*m.TurningCircleMM/360/m.WheelCircumMM,
+	)
+	for i := range w {
+		w[i] /= m.WheelScale[i]
+	}
+	return w
+}
+
+// Motion returns the motion of the bot given its wheel rotations.
+func (m Model) Motion(w picobldc.PerMotorVal[float64]) Motion {
+	for i := range w {
+		w[i] *= m.WheelScale[i]
+	}
+	throttle, translation, rotation := Unmix(w)
+	return Motion{
+		AheadMM:    throttle * m.WheelCircumMM,
+		LeftMM:     translation * m.WheelCircumMM / m.SidewaysSlip,
+		YawDegrees: rotation * m.WheelCircumMM * 360 / m.TurningCircleMM,
+	}
+}
+
+// Desaturate scales the wheel speeds down together so that none of them is faster than limit, in
+// either direction.  Scaling them all by the same factor keeps the bot going in the same direction
+// and turning at the same rate relative to its speed.  It returns the factor that was applied.
+func Desaturate(w picobldc.PerMotorVal[float64], limit float64) (picobldc.PerMotorVal[float64], float64) {
+	biggest := 0.0
+	for _, s := range w {
+		biggest = max(biggest, math.Abs(s))
+	}
+	if biggest <= limit {
+		return w, 1
+	}
+	scale := limit / biggest
+	for i := range w {
+		w[i] *= scale
+	}
+	return w, scale
+}
+
+// MotorSpeeds converts wheel RPS to the Pico-BLDC's format, in the order that SetMotorSpeeds takes
+// them.
+func MotorSpeeds(rps picobldc.PerMotorVal[float64]) (fl, fr, bl, br int16) {
+	return picobldc.RPSToMotorSpeed(rps[picobldc.FrontLeft]),
+		picobldc.RPSToMotorSpeed(rps[picobldc.FrontRight]),
+		picobldc.RPSToMotorSpeed(rps[picobldc.BackLeft]),
+		picobldc.RPSToMotorSpeed(rps[picobldc.BackRight])
+}
+
+// LimitMotorSpeeds applies Desaturate to speeds in the Pico-BLDC's format.
+func LimitMotorSpeeds(fl, fr, bl, br int16, limitRPS float64) (int16, int16, int16, int16) {
+	var w picobldc.PerMotorVal[float64]
+	w[picobldc.FrontLeft] = float64(fl) * picobldc.SpeedRPSLSB
+	w[picobldc.FrontRight] = float64(fr) * picobldc.SpeedRPSLSB
+	w[picobldc.BackLeft] = float64(bl) * picobldc.SpeedRPSLSB
+	w[picobldc.BackRight] = float64(br) * picobldc.SpeedRPSLSB
+	w, scale := Desaturate(w, limitRPS)
+	if scale == 1 {
+		return fl, fr, bl, br
+	}
+	return MotorSpeeds(w)
+}
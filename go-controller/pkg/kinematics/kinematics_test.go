@@ -0,0 +1,56 @@
+package kinematics
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
+)
+
+func TestRoundTrip(t *testing.T) {
+	m := Default()
+	m.WheelScale[picobldc.BackLeft] = 0.95
+
+	for _, motion := range []Motion{
+		{AheadMM: 300},
+		{LeftMM: -200},
+		{YawDegrees: 90},
+		{AheadMM: -150, LeftMM: 120, YawDegrees: -30},
+	} {
+		got := m.Motion(m.Wheels(motion))
+		if math.Abs(got.AheadMM-motion.AheadMM) > 1e-9 ||
+			math.Abs(got.LeftMM-motion.LeftMM) > 1e-9 ||
+			math.Abs(got.YawDegrees-motion.YawDegrees) > 1e-9 {
+			t.Errorf("%+v came back as %+v", motion, got)
+		}
+	}
+}
+
+func TestAheadAndSideways(t *testing.T) {
+	m := Default()
+
+	// Left and right wheels turn in opposite directions to go ahead.
+	w := m.Wheels(Motion{AheadMM: m.WheelCircumMM})
+	if w != (picobldc.PerMotorVal[float64]{-1, -1, 1, 1}) {
+		t.Errorf("Unexpected wheel rotations for one turn ahead: %v", w)
+	}
+
+	// Sideways needs more turns of the wheels for the same distance.
+	w = m.Wheels(Motion{LeftMM: m.WheelCircumMM})
+	if math.Abs(w[picobldc.BackLeft]-MecFac) > 1e-9 || math.Abs(w[picobldc.FrontLeft]+MecFac) > 1e-9 {
+		t.Errorf("Unexpected wheel rotations for one turn left: %v", w)
+	}
+}
+
+func TestDesaturate(t *testing.T) {
+	// The fastest wheel is going backwards; the old max()-based scaling missed it.
+	w, scale := Desaturate(picobldc.PerMotorVal[float64]{-8, 2, 4, -1}, 4)
+	if scale != 0.5 || w != (picobldc.PerMotorVal[float64]{-4, 1, 2, -0.5}) {
+		t.Errorf("Unexpected desaturation: %v scale=%v", w, scale)
+	}
+
+	w, scale = Desaturate(picobldc.PerMotorVal[float64]{-3, 2, 4, -1}, 4)
+	if scale != 1 || w != (picobldc.PerMotorVal[float64]{-3, 2, 4, -1}) {
+		t.Errorf("Speeds within the limit shouldn't change: %v scale=%v", w, scale)
+	}
+}
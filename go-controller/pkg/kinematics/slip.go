@@ -0,0 +1,94 @@
+package kinematics
+
+import (
+	"math"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder/angle"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
+)
+
+// SlipConfig controls how much the wheels and the IMU may disagree about how far the bot has turned
+// before we decide that the wheels are slipping.  The allowance grows with the amount of turning
+// since our model of rotation isn't perfect.
+type SlipConfig struct {
+	Window time.Duration
+
+	ToleranceDegrees  float64
+	ToleranceFraction float64 // Of the IMU's yaw change.
+
+	// Once past the tolerance, confidence in the odometry falls linearly to 0 over this many
+	// degrees of disagreement.
+	FullSlipDegrees float64
+}
+
+func DefaultSlipConfig() SlipConfig {
+	return SlipConfig{
+		Window:            300 * time.Millisecond,
+		ToleranceDegrees:  3,
+		ToleranceFraction: 0.25,
+		FullSlipDegrees:   15,
+	}
+}
+
+// SlipStatus is the slip detector's verdict on the last Window.
+type SlipStatus struct {
+	Time time.Time
+
+	OdometryYawDegrees float64
+	IMUYawDegrees      float64
+
+	Slipping bool
+	// Confidence is how far to trust the wheel odometry, from 0 (not at all) to 1.
+	Confidence float64
+}
+
+type slipSample struct {
+	time      time.Time
+	rotations picobldc.PerMotorVal[float64]
+	yaw       angle.PlusMinus180
+}
+
+// SlipDetector spots wheel slip by comparing the rotation of the bot according to the wheels with
+// the rotation according to the IMU.  The IMU is far more trustworthy so a disagreement means that
+// the wheels have slipped, and so the distance that they report is suspect too.
+type SlipDetector struct {
+	Model  Model
+	Config SlipConfig
+
+	samples []slipSample
+}
+
+func NewSlipDetector(model Model, config SlipConfig) *SlipDetector {
+	return &SlipDetector{Model: model, Config: config}
+}
+
+// Update adds a sample of the accumulated wheel rotations and the IMU's yaw and returns the verdict
+// over the window that ends with it.
+func (d *SlipDetector) Update(t time.Time, rotations picobldc.PerMotorVal[float64], yaw angle.PlusMinus180) SlipStatus {
+	d.samples = append(d.samples, slipSample{t, rotations, yaw})
+	// Keep one sample from before the window so that the window is always covered.
+	for len(d.samples) > 2 && t.Sub(d.samples[1].time) >= d.Config.Window {
+		d.samples = d.samples[1:]
+	}
+
+	first := d.samples[0]
+	var delta picobldc.PerMotorVal[float64]
+	for m := range rotations {
+		delta[m] = rotations[m] - first.rotations[m]
+	}
+	s := SlipStatus{
+		Time:               t,
+		OdometryYawDegrees: d.Model.Motion(delta).YawDegrees,
+		IMUYawDegrees:      yaw.Sub(first.yaw).Float(),
+		Confidence:         1,
+	}
+
+	tolerance := d.Config.ToleranceDegrees + d.Config.ToleranceFraction*math.Abs(s.IMUYawDegrees)
+	excess := math.Abs(s.OdometryYawDegrees-s.IMUYawDegrees) - tolerance
+	if excess > 0 {
+		s.Slipping = true
+		s.Confidence = max(0, 1-excess/d.Config.FullSlipDegrees)
+	}
+	return s
+}
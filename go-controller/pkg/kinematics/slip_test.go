@@ -0,0 +1,54 @@
+package kinematics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder/angle"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
+)
+
+func TestSlipDetector(t *testing.T) {
+	m := Default()
+	d := NewSlipDetector(m, DefaultSlipConfig())
+
+	start := time.Unix(1000, 0)
+	var rotations picobldc.PerMotorVal[float64]
+	yaw := 0.0
+	step := func(i int, wheelYawPerStep, imuYawPerStep float64) SlipStatus {
+		w := m.Wheels(Motion{YawDegrees: wheelYawPerStep})
+		for j := range rotations {
+			rotations[j] += w[j]
+		}
+		yaw += imuYawPerStep
+		return d.Update(start.Add(time.Duration(i)*10*time.Millisecond), rotations, angle.FromFloat(yaw))
+	}
+
+	// Turning at 90 degrees/s with the wheels and IMU agreeing.
+	i := 0
+	for ; i < 100; i++ {
+		if s := step(i, 0.9, 0.9); s.Slipping || s.Confidence != 1 {
+			t.Fatalf("Unexpected slip at step %d: %+v", i, s)
+		}
+	}
+
+	// Wheels spin but the bot doesn't turn.
+	var s SlipStatus
+	for ; i < 130; i++ {
+		s = step(i, 0.9, 0)
+	}
+	if !s.Slipping || s.Confidence >= 1 {
+		t.Fatalf("Expected slip to be detected: %+v", s)
+	}
+	if s.OdometryYawDegrees < 25 || s.IMUYawDegrees != 0 {
+		t.Fatalf("Unexpected yaw over the window: %+v", s)
+	}
+
+	// Once the window has moved past the slip, it clears.
+	for ; i < 200; i++ {
+		s = step(i, 0.9, 0.9)
+	}
+	if s.Slipping || s.Confidence != 1 {
+		t.Fatalf("Expected slip to clear: %+v", s)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/hardware"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder"
 
 	"fmt"
 	"sort"
@@ -58,6 +59,7 @@ func New(hw hardware.Interface) *MazeMode {
 	mm.baseSpeedPct = mm.tunables.Create("Base speed %", 20)
 	mm.topSpeedPct = mm.tunables.Create("Top speed %", 50)
 	mm.speedUpDist = mm.tunables.Create("Speed up dist (mm)", 150)
+	hardware.AddHeadingGainTunables(&mm.tunables, hw, hardware.AbsoluteGains)
 
 	return mm
 }
@@ -148,6 +150,10 @@ func (m *MazeMode) startSequence() {
 		fmt.Println("MAZE: Already running")
 		return
 	}
+	if battery := m.hw.BatteryStatus(); !battery.AutonomousAllowed() {
+		fmt.Println("MAZE: Battery too low to start sequence:", battery.Level)
+		return
+	}
 
 	fmt.Println("MAZE: Starting sequence...")
 	m.running = true
@@ -171,24 +177,42 @@ func (m *MazeMode) runSequence(ctx context.Context) {
 
 	var readings hardware.DistanceReadings
 
-	readSensors := func() {
+	// readSensors returns false if there are no new readings, because the sensors have gone stale or
+	// ctx is done.  The filters then still hold the old readings so don't steer by them.
+	readSensors := func() bool {
 		// Read the sensors
 		msg := "MAZE readings "
-		readings = m.hw.CurrentDistanceReadings(readings.Revision)
+		newReadings, err := m.hw.WaitForDistanceReadings(ctx, readings.Revision)
+		if err != nil {
+			fmt.Println("MAZE: Failed to read sensors:", err)
+			return false
+		}
+		readings = newReadings
 		for j, r := range readings.Readings {
 			prettyPrinted := "-"
-			readingInMM, err := r.DistanceMM, r.Error
-			filters[j].Accumulate(readingInMM, readings.CaptureTime)
-			if readingInMM == vl53l5cx.RangeTooFar {
+			filters[j].Accumulate(r.DistanceMM, r.CaptureTime)
+			switch r.Health {
+			case hardware.ReadingTooFar:
 				prettyPrinted = ">2000mm"
-			} else if err != nil {
+			case hardware.ReadingFailed:
 				prettyPrinted = "<failed>"
-			} else {
-				prettyPrinted = fmt.Sprintf("%dmm", readingInMM)
+			default:
+				prettyPrinted = fmt.Sprintf("%dmm", r.DistanceMM)
 			}
 			msg += fmt.Sprintf("%s=%5s/%5dmm ", filters[j].Name, prettyPrinted, filters[j].BestGuess())
 		}
 		fmt.Println(msg)
+		return true
+	}
+
+	// readSensorsOrStop is for the wall following, which steers by the readings: without new readings,
+	// it stops the bot and returns false so that we don't drive on blind.
+	readSensorsOrStop := func(hh hardware.HeadingAbsolute) bool {
+		if readSensors() {
+			return true
+		}
+		hh.SetThrottle(0)
+		return false
 	}
 
 	flushSensors := func() {
@@ -247,7 +271,9 @@ func (m *MazeMode) runSequence(ctx context.Context) {
 			}
 
 			baseSpeed := float64(m.baseSpeedPct.Get())
-			readSensors()
+			if !readSensorsOrStop(hh) {
+				continue
+			}
 
 			// If we reach a wall in front, break out and do the turn.
 			var numGoodForwardReadings int
@@ -377,11 +403,23 @@ func (m *MazeMode) runSequence(ctx context.Context) {
 		fmt.Println("MAZE: Turn start heading:", startHeading)
 
 		hh.AddHeadingDelta(sign * 90)
-		measuredErr, err := hh.Wait(ctx)
-		if err != nil {
-			fmt.Println("MAZE: Error from wait: ", err)
+		result := hh.WaitSettled(ctx, 1, 100*time.Millisecond, 3*time.Second)
+		fmt.Println("MAZE: Turn", result)
+		if result.Outcome == headingholder.TimedOut || result.Outcome == headingholder.Oscillating {
+			// Following the walls at the wrong heading would drive us into one; give the turn one more
+			// chance.
+			result = hh.WaitSettled(ctx, 1, 100*time.Millisecond, 3*time.Second)
+			fmt.Println("MAZE: Second wait for turn:", result)
+		}
+		switch result.Outcome {
+		case headingholder.Cancelled:
+			return
+		case headingholder.TimedOut, headingholder.Oscillating:
+			fmt.Println("MAZE: Turn didn't settle, stopping:", result)
+			hh.SetThrottle(0)
 			return
 		}
+		measuredErr := result.ResidualDegrees
 
 		flushSensors()
 
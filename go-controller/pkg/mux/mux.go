@@ -3,7 +3,7 @@ package mux
 import (
 	"fmt"
 
-	"golang.org/x/exp/io/i2c"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/i2cbus"
 )
 
 const (
@@ -29,11 +29,15 @@ type Interface interface {
 }
 
 type Mux struct {
-	dev *i2c.Device
+	dev i2cbus.Device
 }
 
 func New(deviceFile string) (Interface, error) {
-	dev, err := i2c.Open(&i2c.Devfs{deviceFile}, MuxAddr)
+	return NewOnBus(i2cbus.Devfs(deviceFile))
+}
+
+func NewOnBus(bus i2cbus.Bus) (Interface, error) {
+	dev, err := i2cbus.Open(bus, MuxAddr)
 	if err != nil {
 		return nil, err
 	}
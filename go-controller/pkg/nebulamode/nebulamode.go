@@ -12,14 +12,21 @@ import (
 	"time"
 
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/hardware"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/joystick"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/mazemode"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/rainbow"
-	"github.com/tigerbot-team/tigerbot/go-controller/pkg/vl53l5cx"
 	"gocv.io/x/gocv"
 	yaml "gopkg.in/yaml.v2"
 )
 
+// How closely and for how long a turn has to settle before we carry on.
+const (
+	turnToleranceDegrees = 1
+	turnSettleTime       = 100 * time.Millisecond
+	turnTimeout          = 3 * time.Second
+)
+
 type NebulaConfig struct {
 	MaxSpeed float64
 	MinSpeed float64
@@ -34,6 +41,10 @@ type NebulaConfig struct {
 	CentralRegionYPercent     int
 	LeftRightPositions        int
 	ValPenaltyPerHueDeviation float64
+
+	// Profile for the turns to the corners, in degrees/s and degrees/s².
+	TurnMaxRate  float64
+	TurnMaxAccel float64
 }
 
 type NebulaMode struct {
@@ -78,6 +89,9 @@ func New(hw hardware.Interface) *NebulaMode {
 			CentralRegionYPercent:     15,
 			LeftRightPositions:        12,
 			ValPenaltyPerHueDeviation: 1,
+
+			TurnMaxRate:  120,
+			TurnMaxAccel: 360,
 		},
 	}
 	for _, colour := range m.config.Sequence {
@@ -166,6 +180,10 @@ func (m *NebulaMode) startSequence() {
 		fmt.Println("NEBULA: Already running")
 		return
 	}
+	if battery := m.hw.BatteryStatus(); !battery.AutonomousAllowed() {
+		fmt.Println("NEBULA: Battery too low to start sequence:", battery.Level)
+		return
+	}
 
 	fmt.Println("NEBULA: Starting sequence...")
 	m.running = true
@@ -329,24 +347,43 @@ func (m *NebulaMode) runSequence(ctx context.Context) {
 
 	var readings hardware.DistanceReadings
 
-	readSensors := func() {
+	// readSensors returns false if there are no new readings, because the sensors have gone stale or
+	// ctx is done.  The filters then still hold the old readings so don't steer by them.
+	readSensors := func() bool {
 		// Read the sensors
 		msg := "NEBULA: readings "
-		readings = m.hw.CurrentDistanceReadings(readings.Revision)
+		newReadings, err := m.hw.WaitForDistanceReadings(ctx, readings.Revision)
+		if err != nil {
+			fmt.Println("NEBULA: Failed to read sensors:", err)
+			return false
+		}
+		readings = newReadings
 		for j, r := range readings.Readings {
 			prettyPrinted := "-"
-			readingInMM, err := r.DistanceMM, r.Error
-			filters[j].Accumulate(readingInMM, readings.CaptureTime)
-			if readingInMM == vl53l5cx.RangeTooFar {
+			filters[j].Accumulate(r.DistanceMM, r.CaptureTime)
+			switch r.Health {
+			case hardware.ReadingTooFar:
 				prettyPrinted = ">2000mm"
-			} else if err != nil {
+			case hardware.ReadingFailed:
 				prettyPrinted = "<failed>"
-			} else {
-				prettyPrinted = fmt.Sprintf("%dmm", readingInMM)
+			default:
+				prettyPrinted = fmt.Sprintf("%dmm", r.DistanceMM)
 			}
 			msg += fmt.Sprintf("%s=%5s/%.1fmm ", filters[j].Name, prettyPrinted, filters[j].Predict())
 		}
 		fmt.Println(msg)
+		return true
+	}
+
+	// readSensorsOrStop is for the advance, which watches the front readings for the corner; it stops
+	// the bot while there are no new readings.  Reversing goes by the wheel distances so it doesn't
+	// need to stop.
+	readSensorsOrStop := func(hh hardware.HeadingAbsolute) bool {
+		if readSensors() {
+			return true
+		}
+		hh.SetThrottle(0)
+		return false
 	}
 
 	leftRear := filters[0]
@@ -392,9 +429,13 @@ func (m *NebulaMode) runSequence(ctx context.Context) {
 
 		// Turn to take photos of the four corners.
 		for ii, cornerHeading := range cornerHeadings {
-			hh.SetHeading(cornerHeading)
-			residualError, _ := hh.Wait(ctx)
-			fmt.Println("NEBULA: Completed turn, residual error: ", residualError)
+			hh.SetHeadingWithProfile(cornerHeading, m.config.TurnMaxRate, m.config.TurnMaxAccel)
+			result := hh.WaitSettled(ctx, turnToleranceDegrees, turnSettleTime, turnTimeout)
+			fmt.Println("NEBULA: Completed turn:", result)
+			if result.Outcome == headingholder.Cancelled {
+				return
+			}
+			// A corner photo is still useful if we're a little off.
 			hsv[ii], err = m.takePicture()
 			if err != nil {
 				m.fatal(err)
@@ -415,10 +456,17 @@ func (m *NebulaMode) runSequence(ctx context.Context) {
 
 		// Rotating phase.
 		hh.SetThrottle(0)
-		hh.SetHeading(cornerHeadings[index])
-		residualError, _ := hh.Wait(ctx)
-
-		fmt.Println("NEBULA: Completed turn, residual error: ", residualError)
+		hh.SetHeadingWithProfile(cornerHeadings[index], m.config.TurnMaxRate, m.config.TurnMaxAccel)
+		result := hh.WaitSettled(ctx, turnToleranceDegrees, turnSettleTime, turnTimeout)
+		fmt.Println("NEBULA: Completed turn:", result)
+		if result.Outcome == headingholder.TimedOut || result.Outcome == headingholder.Oscillating {
+			// Setting off in the wrong direction would miss the ball; give it one more chance.
+			result = hh.WaitSettled(ctx, turnToleranceDegrees, turnSettleTime, turnTimeout)
+			fmt.Println("NEBULA: Second wait for turn:", result)
+		}
+		if result.Outcome == headingholder.Cancelled {
+			return
+		}
 
 		time.Sleep(100 * time.Millisecond)
 
@@ -433,7 +481,9 @@ func (m *NebulaMode) runSequence(ctx context.Context) {
 		hh.SetThrottle(m.config.MinSpeed)
 
 		for ctx.Err() == nil {
-			readSensors()
+			if !readSensorsOrStop(hh) {
+				continue
+			}
 			traveledMM := distanceTraveledMM()
 			fmt.Println("NEBULA: Target colour:", m.config.Sequence[ii], "Advancing", traveledMM, "mm")
 
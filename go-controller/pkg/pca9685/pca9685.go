@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"time"
 
-	"golang.org/x/exp/io/i2c"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/i2cbus"
 )
 
 const (
@@ -39,11 +39,15 @@ type Interface interface {
 }
 
 type PCA9685 struct {
-	dev *i2c.Device
+	dev i2cbus.Device
 }
 
 func New(deviceFile string) (Interface, error) {
-	dev, err := i2c.Open(&i2c.Devfs{deviceFile}, DefaultAddr)
+	return NewOnBus(i2cbus.Devfs(deviceFile))
+}
+
+func NewOnBus(bus i2cbus.Bus) (Interface, error) {
+	dev, err := i2cbus.Open(bus, DefaultAddr)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,67 @@
+package pca9685
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/i2cbus"
+)
+
+const oscillatorHz = 25e6
+
+func TestConfigureSetsPrescalerWhileAsleep(t *testing.T) {
+	bus := i2cbus.NewFake()
+	regs := i2cbus.NewRegisters(1)
+	bus.Attach(DefaultAddr, regs)
+	p, err := NewOnBus(bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Configure(); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+
+	prescale := regs.Get(RegPreScale)[0]
+	freq := oscillatorHz / (4096 * (float64(prescale) + 1))
+	if math.Abs(freq-float64(time.Second/PWMPeriod)) > 0.5 {
+		t.Errorf("Prescaler %#x gives %.2fHz", prescale, freq)
+	}
+
+	// The prescaler can only be changed while the oscillator is asleep.
+	const sleep = 0x10
+	var mode1 byte
+	for _, w := range regs.Writes() {
+		switch w.Reg {
+		case RegMode1:
+			mode1 = w.Value[0]
+		case RegPreScale:
+			if mode1&sleep == 0 {
+				t.Errorf("Prescaler written while awake (MODE1=%#x)", mode1)
+			}
+		}
+	}
+	if mode1&sleep != 0 {
+		t.Errorf("Left asleep (MODE1=%#x)", mode1)
+	}
+}
+
+func TestSetServo(t *testing.T) {
+	bus := i2cbus.NewFake()
+	regs := i2cbus.NewRegisters(1)
+	bus.Attach(DefaultAddr, regs)
+	p, err := NewOnBus(bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.SetServo(2, 0.5); err != nil {
+		t.Fatal(err)
+	}
+	// Off time for a 1.5ms pulse (the pulse limits are rounded down to whole counts), low byte first.
+	base := byte(RegLEDBase + 2*4)
+	off := int(regs.Get(base + 2)[0]) | int(regs.Get(base + 3)[0])<<8
+	if off != 306 {
+		t.Fatalf("Servo off time = %d, expected 306", off)
+	}
+}
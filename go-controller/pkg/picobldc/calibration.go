@@ -0,0 +1,74 @@
+package picobldc
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// CalibrationFile holds this robot's motor calibration, as written by cmd/picocalibrate.  The
+// calibration depends on the motors so it needs redoing if one is swapped.
+const CalibrationFile = "/cfg/pico-calibration.yaml"
+
+// CalibrationConfig is the commutation calibration word for each motor, in the order of the Pico's
+// registers.
+type CalibrationConfig struct {
+	Words []uint16
+}
+
+// LoadCalibration reads the calibration from the given file.
+func LoadCalibration(path string) ([NumMotors]uint16, error) {
+	var words [NumMotors]uint16
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return words, err
+	}
+	var config CalibrationConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return words, err
+	}
+	if len(config.Words) != NumMotors {
+		return words, fmt.Errorf("%s: expected %d calibration words, got %d", path, NumMotors, len(config.Words))
+	}
+	for i, w := range config.Words {
+		if w == 0 {
+			// The driver takes 0 to mean that the Pico has lost its calibration.
+			return words, fmt.Errorf("%s: calibration word %d is 0", path, i)
+		}
+		words[i] = w
+	}
+	return words, nil
+}
+
+// SaveCalibration writes the calibration to the given file.
+func SaveCalibration(path string, words [NumMotors]uint16) error {
+	data, err := yaml.Marshal(&CalibrationConfig{Words: words[:]})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0666)
+}
+
+// loadCalibrationOrDefault returns the calibration from CalibrationFile, falling back to the
+// calibration of our original motors.
+func loadCalibrationOrDefault() [NumMotors]uint16 {
+	words, err := LoadCalibration(CalibrationFile)
+	if err != nil {
+		fmt.Println("Pico: failed to load calibration, using defaults:", err)
+		return defaultCalibration
+	}
+	fmt.Printf("Pico: using calibration from %s: %04x\n", CalibrationFile, words)
+	return words
+}
+
+// CalibrationWords reads back the calibration registers.
+func (p *PicoBLDC) CalibrationWords() (words [NumMotors]uint16, err error) {
+	for m := range words {
+		words[m], err = p.readReg(RegMot0Calib + Register(m))
+		if err != nil {
+			return
+		}
+	}
+	return
+}
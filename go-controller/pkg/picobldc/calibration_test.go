@@ -0,0 +1,104 @@
+package picobldc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/i2cbus"
+)
+
+func TestCalibrationWorkflow(t *testing.T) {
+	// Calibrate a board with new motors...
+	emu := NewEmulator()
+	emu.CalibrationTime = 10 * time.Millisecond
+	emu.CalibrationResult = [NumMotors]uint16{0x0111, 0x0222, 0x0333, 0x0444}
+	bus := i2cbus.NewFake()
+	bus.Attach(PicoAddr, emu)
+	pico, err := NewOnBus(bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pico.Calibrate(); err != nil {
+		t.Fatal(err)
+	}
+	words, err := pico.CalibrationWords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if words != emu.CalibrationResult {
+		t.Fatalf("Read back %04x, expected %04x", words, emu.CalibrationResult)
+	}
+
+	path := filepath.Join(t.TempDir(), "pico-calibration.yaml")
+	if err := SaveCalibration(path, words); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCalibration(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != words {
+		t.Fatalf("Loaded %04x, expected %04x", loaded, words)
+	}
+
+	// ...then, after a firmware update wipes the registers, the driver restores the saved calibration
+	// rather than the defaults.
+	emu = NewEmulator()
+	bus.Attach(PicoAddr, emu)
+	pico, err = NewOnBus(bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pico.calibration = loaded
+	if err := pico.SetMotorSpeeds(0, 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	for m := 0; m < NumMotors; m++ {
+		if v := emu.Register(RegMot0Calib + Register(m)); v != loaded[m] {
+			t.Errorf("Calibration register %d = %04x, expected %04x", m, v, loaded[m])
+		}
+	}
+}
+
+func TestLoadCalibrationRejectsBadFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, contents := range map[string]string{
+		"short": "words: [1, 2, 3]\n",
+		"zero":  "words: [1, 0, 3, 4]\n",
+		"junk":  "words: banana\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadCalibration(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := LoadCalibration(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestCalibrateFailsWithoutMotorPower(t *testing.T) {
+	emu := NewEmulator()
+	emu.BatteryVolts = 0
+	bus := i2cbus.NewFake()
+	bus.Attach(PicoAddr, emu)
+	pico, err := NewOnBus(bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- pico.Calibrate() }()
+	select {
+	case err := <-done:
+		if err != ErrCalibrationFault {
+			t.Fatalf("Expected ErrCalibrationFault, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Calibrate didn't give up on the fault")
+	}
+}
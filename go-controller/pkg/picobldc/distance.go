@@ -1,16 +1,78 @@
 package picobldc
 
+import (
+	"math"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/chassis"
+)
+
+const (
+	// VelocityTimeConstant is the time constant of the filter on the measured wheel speeds.  The
+	// travel counters count in 1/256 rotations so, at the I2C loop's 25ms poll interval, the raw
+	// speeds are quantised to ~0.16 RPS.
+	VelocityTimeConstant = 100 * time.Millisecond
+
+	// A wheel counts as stalled if it has been commanded to turn at least stallMinRPS but has been
+	// turning at less than stallFraction of that, in the right direction, for stallTime.  The time
+	// allows for the motors spinning up after a change of speed.
+	stallMinRPS   = 0.5
+	stallFraction = 0.3
+	stallTime     = 300 * time.Millisecond
+)
+
 type distanceProvider interface {
 	RawDistancesTraveled() (PerMotorVal[int16], error)
 }
 
+// WheelVelocities is a measurement of how fast the wheels are turning, compared to the speeds that
+// they were asked to turn at.
+type WheelVelocities struct {
+	Time         time.Time // When the travel counters were read.
+	RPS          PerMotorVal[float64]
+	MMPerS       PerMotorVal[float64]
+	CommandedRPS PerMotorVal[float64]
+	// DiscrepancyRPS is the commanded speed minus the measured speed.  It's normal for it to be large
+	// for a moment after a change of speed; Stalled flags a persistent problem.
+	DiscrepancyRPS PerMotorVal[float64]
+	Stalled        PerMotorVal[bool]
+}
+
+func NewWheelVelocities(t time.Time, rps, commandedRPS PerMotorVal[float64]) WheelVelocities {
+	v := WheelVelocities{
+		Time:         t,
+		RPS:          rps,
+		CommandedRPS: commandedRPS,
+	}
+	for m := range rps {
+		v.MMPerS[m] = rps[m] * chassis.WheelCircumMM
+		v.DiscrepancyRPS[m] = commandedRPS[m] - rps[m]
+	}
+	return v
+}
+
+func (v WheelVelocities) AnyStalled() bool {
+	for _, s := range v.Stalled {
+		if s {
+			return true
+		}
+	}
+	return false
+}
+
 type DistanceTracker struct {
 	pico distanceProvider
 
 	doneFirstPoll bool
 	lastRawValues PerMotorVal[int16]
+	lastPollTime  time.Time
 
 	accumulator PerMotorVal[int64]
+
+	rps             PerMotorVal[float64]
+	commandedRPS    PerMotorVal[float64]
+	underspeedSince PerMotorVal[time.Time]
+	stalled         PerMotorVal[bool]
 }
 
 func NewDistanceTracker(pico distanceProvider) *DistanceTracker {
@@ -20,24 +82,69 @@ func NewDistanceTracker(pico distanceProvider) *DistanceTracker {
 }
 
 func (d *DistanceTracker) Poll() error {
+	return d.pollAt(time.Now())
+}
+
+func (d *DistanceTracker) pollAt(now time.Time) error {
 	raw, err := d.pico.RawDistancesTraveled()
 	if err != nil {
 		return err
 	}
 
 	if d.doneFirstPoll {
+		dt := now.Sub(d.lastPollTime).Seconds()
+		alpha := 1 - math.Exp(-dt/VelocityTimeConstant.Seconds())
 		for m, newD := range raw {
 			oldD := d.lastRawValues[m]
 			delta := newD - oldD
 			d.accumulator[m] += int64(delta)
+			if dt > 0 {
+				rawRPS := float64(delta) / 256.0 / dt
+				d.rps[m] += (rawRPS - d.rps[m]) * alpha
+			}
 		}
+		d.updateStalls(now)
 	}
 
 	d.lastRawValues = raw
+	d.lastPollTime = now
 	d.doneFirstPoll = true
 	return nil
 }
 
+func (d *DistanceTracker) updateStalls(now time.Time) {
+	for m, c := range d.commandedRPS {
+		underspeed := math.Abs(c) >= stallMinRPS && d.rps[m]*math.Copysign(1, c) < stallFraction*math.Abs(c)
+		if !underspeed {
+			d.underspeedSince[m] = time.Time{}
+			d.stalled[m] = false
+			continue
+		}
+		if d.underspeedSince[m].IsZero() {
+			d.underspeedSince[m] = now
+		}
+		d.stalled[m] = now.Sub(d.underspeedSince[m]) >= stallTime
+	}
+}
+
+// SetCommandedSpeeds records the speeds that the motors were last set to, in the Pico's format, for
+// comparison with the measured speeds.
+func (d *DistanceTracker) SetCommandedSpeeds(frontLeft, frontRight, backLeft, backRight int16) {
+	var commanded PerMotorVal[float64]
+	commanded[FrontLeft] = float64(frontLeft) * SpeedRPSLSB
+	commanded[FrontRight] = float64(frontRight) * SpeedRPSLSB
+	commanded[BackLeft] = float64(backLeft) * SpeedRPSLSB
+	commanded[BackRight] = float64(backRight) * SpeedRPSLSB
+	d.commandedRPS = commanded
+}
+
+// Velocities returns the filtered wheel speeds as of the last poll.
+func (d *DistanceTracker) Velocities() WheelVelocities {
+	v := NewWheelVelocities(d.lastPollTime, d.rps, d.commandedRPS)
+	v.Stalled = d.stalled
+	return v
+}
+
 func (d *DistanceTracker) AccumulatedRotations() (rotations PerMotorVal[float64]) {
 	for m, v := range d.accumulator {
 		rotations[m] = float64(v) / 256.0
@@ -45,6 +152,12 @@ func (d *DistanceTracker) AccumulatedRotations() (rotations PerMotorVal[float64]
 	return
 }
 
+// Resync discards the last raw reading, without losing the accumulated rotations.  Needed after the
+// Pico reboots and its travel counters restart from 0.
+func (d *DistanceTracker) Resync() {
+	d.doneFirstPoll = false
+}
+
 func (d *DistanceTracker) Zero() {
 	d.accumulator = PerMotorVal[int64]{}
 }
@@ -0,0 +1,74 @@
+package picobldc
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/chassis"
+)
+
+func TestDistanceTrackerVelocities(t *testing.T) {
+	pico, emu, clock := newEmulatedPico(t)
+	tracker := NewDistanceTracker(pico)
+	if err := tracker.pollAt(clock.now); err != nil {
+		t.Fatal(err)
+	}
+
+	fl, fr, bl, br := RPSToMotorSpeed(2), RPSToMotorSpeed(-1), int16(0), RPSToMotorSpeed(0.5)
+	poll := func(d time.Duration) WheelVelocities {
+		for end := clock.now.Add(d); clock.now.Before(end); {
+			clock.Advance(25 * time.Millisecond)
+			if err := pico.SetMotorSpeeds(fl, fr, bl, br); err != nil {
+				t.Fatal(err)
+			}
+			tracker.SetCommandedSpeeds(fl, fr, bl, br)
+			if err := tracker.pollAt(clock.now); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return tracker.Velocities()
+	}
+
+	v := poll(time.Second)
+	if !v.Time.Equal(clock.now) {
+		t.Errorf("Velocities timestamped %v, expected %v", v.Time, clock.now)
+	}
+	for m, expected := range map[int]float64{FrontLeft: 2, FrontRight: -1, BackLeft: 0, BackRight: 0.5} {
+		if math.Abs(v.RPS[m]-expected) > 0.05 {
+			t.Errorf("Motor %d measured at %.3f RPS, expected %.3f", m, v.RPS[m], expected)
+		}
+		if math.Abs(v.MMPerS[m]-expected*chassis.WheelCircumMM) > 0.05*chassis.WheelCircumMM {
+			t.Errorf("Motor %d measured at %.1f mm/s, expected %.1f", m, v.MMPerS[m], expected*chassis.WheelCircumMM)
+		}
+		if math.Abs(v.DiscrepancyRPS[m]) > 0.05 {
+			t.Errorf("Motor %d discrepancy %.3f RPS, expected ~0", m, v.DiscrepancyRPS[m])
+		}
+	}
+	if v.AnyStalled() {
+		t.Errorf("Unexpected stall: %v", v.Stalled)
+	}
+
+	// A fault stops the motors while they're still being commanded to turn.  The wheel that was
+	// commanded to stay still shouldn't be flagged.
+	emu.InjectFault()
+	v = poll(stallTime / 2)
+	if v.AnyStalled() {
+		t.Errorf("Stall flagged too soon: %v", v.Stalled)
+	}
+	v = poll(stallTime)
+	expectedStalls := PerMotorVal[bool]{FrontLeft: true, FrontRight: true, BackRight: true}
+	if v.Stalled != expectedStalls {
+		t.Errorf("Stalled %v, expected %v", v.Stalled, expectedStalls)
+	}
+	if v.DiscrepancyRPS[FrontLeft] < 1.5 {
+		t.Errorf("Front left discrepancy %.3f RPS, expected ~2", v.DiscrepancyRPS[FrontLeft])
+	}
+
+	// Commanding the stalled wheels to stop clears the stall.
+	fl, fr, br = 0, 0, 0
+	v = poll(25 * time.Millisecond)
+	if v.AnyStalled() {
+		t.Errorf("Stall not cleared: %v", v.Stalled)
+	}
+}
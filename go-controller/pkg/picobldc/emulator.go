@@ -0,0 +1,322 @@
+package picobldc
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/i2cbus"
+)
+
+const numRegisters = int(RegMot3Travel) + 1
+
+// Emulator emulates the Pico-BLDC firmware's register file so that the driver, the DistanceTracker
+// and the I2C loop can be tested without a board.  Attach it to an i2cbus.Fake at PicoAddr.
+//
+// The model follows the firmware:
+//   - Motors only run when RegCtrl has I2C control and run enabled, and there's no fault or
+//     calibration in progress.  Travel counters integrate the running speeds in units of 1/256
+//     rotation and wrap at 16 bits.
+//   - Reset stops the motors and clears any fault.  It doesn't latch.
+//   - DoCalib runs a calibration that takes CalibrationTime; the motors need power (BatteryVolts at
+//     least MinMotorVolts) or it faults instead.  On success the calibration registers are loaded
+//     from CalibrationResult and RegStatusCalibDone is raised.
+//   - With the watchdog enabled, a gap longer than RegWatchdogTimeout between register writes
+//     zeroes the speeds and raises RegStatusWatchdogExpired.  Writing new speeds restarts the motors;
+//     the flag stays up until it's cleared.
+//   - Status flags are cleared by writing 1s to them.
+//   - Battery voltage sags with the current drawn, which goes up with the running speeds, and the
+//     temperature heads towards a level set by the power dissipated.
+//
+// The exported fields are the physical parameters of the model; they must be set before use.
+type Emulator struct {
+	BatteryVolts        float64 // Open circuit voltage.
+	InternalOhms        float64
+	IdleAmps            float64
+	AmpsPerRPS          float64 // Extra current per motor per RPS.
+	MinMotorVolts       float64 // Below this the motor drivers fault.
+	AmbientC            float64
+	DegreesPerWatt      float64 // Steady state temperature rise.
+	ThermalTimeConstant time.Duration
+	CalibrationTime     time.Duration
+	CalibrationResult   [NumMotors]uint16
+
+	// Now returns the current time; tests can replace it to control the emulator's clock.
+	Now func() time.Time
+
+	lock    sync.Mutex
+	regs    [numRegisters]uint16
+	pointer byte
+
+	lastUpdate    time.Time
+	lastWrite     time.Time
+	watchdogFired bool // Since the last write.
+	calibStarted  time.Time
+	calibrating   bool
+	travel        [NumMotors]float64 // In 1/256 rotations.
+	temperatureC  float64
+	tempOverrideC *float64
+}
+
+var _ i2cbus.Model = (*Emulator)(nil)
+
+// NewEmulator returns an emulator of a healthy board with a fresh 4S battery and empty calibration
+// registers, as after a firmware update.
+func NewEmulator() *Emulator {
+	return &Emulator{
+		BatteryVolts:        16.4,
+		InternalOhms:        0.05,
+		IdleAmps:            0.1,
+		AmpsPerRPS:          0.15,
+		MinMotorVolts:       6,
+		AmbientC:            25,
+		DegreesPerWatt:      1.5,
+		ThermalTimeConstant: 30 * time.Second,
+		CalibrationTime:     200 * time.Millisecond,
+		CalibrationResult:   defaultCalibration,
+		Now:                 time.Now,
+		temperatureC:        25,
+	}
+}
+
+// Tx implements i2cbus.Model.  Registers are 16-bit, big-endian, and auto-increment.
+func (e *Emulator) Tx(w, r []byte) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	now := e.Now()
+	e.advance(now)
+
+	if len(w) > 0 {
+		e.pointer = w[0]
+		data := w[1:]
+		for len(data) >= 2 {
+			e.write(now, Register(e.pointer), binary.BigEndian.Uint16(data))
+			data = data[2:]
+			e.pointer++
+		}
+	}
+	for off := 0; off+2 <= len(r); off += 2 {
+		binary.BigEndian.PutUint16(r[off:], e.read(Register(e.pointer)))
+		e.pointer++
+	}
+	return nil
+}
+
+func (e *Emulator) write(now time.Time, reg Register, value uint16) {
+	if int(reg) >= numRegisters {
+		return
+	}
+	e.lastWrite = now
+	e.watchdogFired = false
+
+	switch reg {
+	case RegCtrl:
+		if value&RegCtrlReset != 0 {
+			for m := 0; m < NumMotors; m++ {
+				e.regs[RegMot0V+Register(m)] = 0
+			}
+			e.regs[RegStatus] &^= uint16(RegStatusFault)
+		}
+		if value&RegCtrlDoCalib != 0 && !e.calibrating {
+			e.regs[RegStatus] &^= uint16(RegStatusCalibDone)
+			if e.supplyVolts() < e.MinMotorVolts {
+				e.fault()
+			} else {
+				e.calibrating = true
+				e.calibStarted = now
+			}
+		}
+		// Reset and DoCalib are actions rather than settings.
+		e.regs[RegCtrl] = value &^ (RegCtrlReset | RegCtrlDoCalib)
+	case RegStatus:
+		e.regs[RegStatus] &^= value
+	case RegFaultCount, RegBattV, RegCurrent, RegPower, RegTemperature,
+		RegMot0Travel, RegMot1Travel, RegMot2Travel, RegMot3Travel:
+		// Read only.
+	default:
+		e.regs[reg] = value
+	}
+}
+
+func (e *Emulator) read(reg Register) uint16 {
+	if int(reg) >= numRegisters {
+		return 0
+	}
+	switch reg {
+	case RegBattV:
+		return uint16(e.supplyVolts() / BattVLSB)
+	case RegCurrent:
+		return uint16(e.currentAmps() / CurrentLSB)
+	case RegPower:
+		return uint16(e.supplyVolts() * e.currentAmps() / PowerLSB)
+	case RegTemperature:
+		return uint16(e.temperature() / TemperatureLSB)
+	case RegMot0Travel, RegMot1Travel, RegMot2Travel, RegMot3Travel:
+		return uint16(int64(math.Floor(e.travel[reg-RegMot0Travel])))
+	}
+	return e.regs[reg]
+}
+
+// advance brings the emulation up to date, integrating travel and temperature and firing the
+// watchdog and calibration timers at the right point in the interval.
+func (e *Emulator) advance(now time.Time) {
+	if e.lastUpdate.IsZero() {
+		e.lastUpdate = now
+		e.lastWrite = now
+	}
+	for e.lastUpdate.Before(now) {
+		end := now
+		var event func()
+		if e.watchdogEnabled() && !e.watchdogFired {
+			if expiry := e.lastWrite.Add(e.watchdogTimeout()); expiry.Before(end) {
+				end = expiry
+				event = e.expireWatchdog
+			}
+		}
+		if e.calibrating {
+			if done := e.calibStarted.Add(e.CalibrationTime); done.Before(end) {
+				end = done
+				event = e.finishCalibration
+			}
+		}
+		if end.Before(e.lastUpdate) {
+			end = e.lastUpdate
+		}
+
+		dt := end.Sub(e.lastUpdate).Seconds()
+		speeds := e.runningSpeeds()
+		for m := range speeds {
+			e.travel[m] += speeds[m] * 256 * dt
+			// Wrap like the firmware's 16-bit counter, keeping the fraction.
+			e.travel[m] = math.Mod(e.travel[m]+32768, 65536)
+			if e.travel[m] < 0 {
+				e.travel[m] += 65536
+			}
+			e.travel[m] -= 32768
+		}
+		if e.ThermalTimeConstant > 0 {
+			target := e.AmbientC + e.DegreesPerWatt*e.supplyVolts()*e.currentAmps()
+			e.temperatureC += (target - e.temperatureC) * (1 - math.Exp(-dt/e.ThermalTimeConstant.Seconds()))
+		}
+		e.lastUpdate = end
+
+		if event == nil {
+			break
+		}
+		event()
+	}
+}
+
+func (e *Emulator) expireWatchdog() {
+	e.watchdogFired = true
+	for m := 0; m < NumMotors; m++ {
+		e.regs[RegMot0V+Register(m)] = 0
+	}
+	e.regs[RegStatus] |= uint16(RegStatusWatchdogExpired)
+}
+
+func (e *Emulator) finishCalibration() {
+	e.calibrating = false
+	for m, v := range e.CalibrationResult {
+		e.regs[RegMot0Calib+Register(m)] = v
+	}
+	e.regs[RegStatus] |= uint16(RegStatusCalibDone)
+}
+
+func (e *Emulator) fault() {
+	e.calibrating = false
+	e.regs[RegStatus] |= uint16(RegStatusFault)
+	e.regs[RegFaultCount]++
+}
+
+func (e *Emulator) watchdogEnabled() bool {
+	return e.regs[RegCtrl]&RegCtrlWatchdogEnable != 0
+}
+
+func (e *Emulator) watchdogTimeout() time.Duration {
+	return time.Duration(e.regs[RegWatchdogTimeout]) * time.Millisecond
+}
+
+func (e *Emulator) runningSpeeds() (rps [NumMotors]float64) {
+	ctrl := e.regs[RegCtrl]
+	if ctrl&RegCtrlEnableI2CControl == 0 || ctrl&RegCtrlRun == 0 || e.calibrating ||
+		e.regs[RegStatus]&uint16(RegStatusFault) != 0 {
+		return
+	}
+	for m := range rps {
+		rps[m] = float64(int16(e.regs[RegMot0V+Register(m)])) * SpeedRPSLSB
+	}
+	return
+}
+
+func (e *Emulator) currentAmps() float64 {
+	amps := e.IdleAmps
+	for _, s := range e.runningSpeeds() {
+		amps += math.Abs(s) * e.AmpsPerRPS
+	}
+	return amps
+}
+
+func (e *Emulator) supplyVolts() float64 {
+	return max(0, e.BatteryVolts-e.currentAmps()*e.InternalOhms)
+}
+
+func (e *Emulator) temperature() float64 {
+	if e.tempOverrideC != nil {
+		return *e.tempOverrideC
+	}
+	return e.temperatureC
+}
+
+// InjectFault raises a motor driver fault, as the firmware does on over-current.  The motors stop until
+// the driver resets the board.
+func (e *Emulator) InjectFault() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.advance(e.Now())
+	e.fault()
+}
+
+// SetTemperature pins the reported temperature; pass nil to go back to the thermal model.
+func (e *Emulator) SetTemperature(c *float64) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.tempOverrideC = c
+}
+
+// Reboot emulates the Pico browning out: all its registers, including the calibration and the
+// watchdog timeout, go back to 0.
+func (e *Emulator) Reboot() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.advance(e.Now())
+	e.regs = [numRegisters]uint16{}
+	e.calibrating = false
+	e.watchdogFired = false
+}
+
+// Status returns the status flags without acknowledging them.
+func (e *Emulator) Status() StatusFlag {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.advance(e.Now())
+	return StatusFlag(e.regs[RegStatus])
+}
+
+// Register returns a register's raw value, as the driver would read it.
+func (e *Emulator) Register(reg Register) uint16 {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.advance(e.Now())
+	return e.read(reg)
+}
+
+// RunningSpeeds returns the speeds that the motors are actually turning at, in RPS.
+func (e *Emulator) RunningSpeeds() PerMotorVal[float64] {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.advance(e.Now())
+	return e.runningSpeeds()
+}
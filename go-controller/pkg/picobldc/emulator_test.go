@@ -0,0 +1,178 @@
+package picobldc
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/i2cbus"
+)
+
+type fakeClock struct {
+	now  time.Time
+	step time.Duration // Added on every read, for code that polls the emulator.
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.now = c.now.Add(c.step)
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newEmulatedPico(t *testing.T) (*PicoBLDC, *Emulator, *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1000, 0)}
+	emu := NewEmulator()
+	emu.Now = clock.Now
+	bus := i2cbus.NewFake()
+	bus.Attach(PicoAddr, emu)
+	pico, err := NewOnBus(bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pico, emu, clock
+}
+
+func TestEmulatorTravel(t *testing.T) {
+	pico, _, clock := newEmulatedPico(t)
+	tracker := NewDistanceTracker(pico)
+	if err := tracker.Poll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pico.SetMotorSpeeds(RPSToMotorSpeed(2), RPSToMotorSpeed(-1), 0, RPSToMotorSpeed(0.5)); err != nil {
+		t.Fatal(err)
+	}
+	// Long enough for the travel counters to wrap a couple of times.
+	for i := 0; i < 300; i++ {
+		clock.Advance(100 * time.Millisecond)
+		if err := pico.SetMotorSpeeds(RPSToMotorSpeed(2), RPSToMotorSpeed(-1), 0, RPSToMotorSpeed(0.5)); err != nil {
+			t.Fatal(err)
+		}
+		if err := tracker.Poll(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rot := tracker.AccumulatedRotations()
+	for m, expected := range map[int]float64{FrontLeft: 60, FrontRight: -30, BackLeft: 0, BackRight: 15} {
+		if math.Abs(rot[m]-expected) > 0.01 {
+			t.Errorf("Motor %d travelled %.3f rotations, expected %.3f", m, rot[m], expected)
+		}
+	}
+}
+
+func TestEmulatorWatchdog(t *testing.T) {
+	pico, emu, clock := newEmulatedPico(t)
+	if err := pico.SetWatchdog(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := pico.SetMotorSpeeds(RPSToMotorSpeed(1), 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Petting the watchdog keeps the motors going.
+	for i := 0; i < 20; i++ {
+		clock.Advance(500 * time.Millisecond)
+		if err := pico.SetMotorSpeeds(RPSToMotorSpeed(1), 0, 0, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if emu.Status()&RegStatusWatchdogExpired != 0 {
+		t.Fatal("Watchdog expired while being petted")
+	}
+
+	clock.Advance(1500 * time.Millisecond)
+	if emu.Status()&RegStatusWatchdogExpired == 0 {
+		t.Fatal("Watchdog didn't expire")
+	}
+	if s := emu.RunningSpeeds(); s[FrontLeft] != 0 {
+		t.Fatalf("Motors still running after watchdog expired: %v", s)
+	}
+	// Travel stopped when the watchdog expired, a second after the last command.
+	if travel := int16(emu.Register(RegMot0Travel + FrontLeft)); travel != 11*256 {
+		t.Fatalf("Unexpected travel %d", travel)
+	}
+
+	// Recovery: new speeds restart the motors.
+	clock.Advance(10 * time.Millisecond)
+	if err := pico.SetMotorSpeeds(RPSToMotorSpeed(1), 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if s := emu.RunningSpeeds(); s[FrontLeft] != 1 {
+		t.Fatalf("Motors didn't restart: %v", s)
+	}
+}
+
+func TestEmulatorCalibration(t *testing.T) {
+	emu := NewEmulator()
+	emu.CalibrationTime = 10 * time.Millisecond
+	emu.CalibrationResult = [NumMotors]uint16{1, 2, 3, 4}
+	bus := i2cbus.NewFake()
+	bus.Attach(PicoAddr, emu)
+	pico, err := NewOnBus(bus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pico.maybeConfigure(true, false, true); err != nil {
+		t.Fatal(err)
+	}
+	for m := 0; m < NumMotors; m++ {
+		if v := emu.Register(RegMot0Calib + Register(m)); v != uint16(m+1) {
+			t.Errorf("Calibration register %d = %d", m, v)
+		}
+	}
+	if emu.Status()&RegStatusCalibDone != 0 {
+		t.Error("Driver didn't acknowledge calibration")
+	}
+}
+
+func TestEmulatorFault(t *testing.T) {
+	pico, emu, clock := newEmulatedPico(t)
+	if err := pico.SetMotorSpeeds(RPSToMotorSpeed(1), 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	emu.InjectFault()
+	if status, err := pico.Status(); err != nil || status&RegStatusFault == 0 {
+		t.Fatalf("Expected fault, got %v, %v", status, err)
+	}
+	if s := emu.RunningSpeeds(); s[FrontLeft] != 0 {
+		t.Fatalf("Motors running despite fault: %v", s)
+	}
+	if n := emu.Register(RegFaultCount); n != 1 {
+		t.Fatalf("Fault count %d", n)
+	}
+
+	clock.step = time.Millisecond
+	if err := pico.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if emu.Status()&RegStatusFault != 0 {
+		t.Fatal("Reset didn't clear fault")
+	}
+}
+
+func TestEmulatorPower(t *testing.T) {
+	pico, emu, clock := newEmulatedPico(t)
+	idleV, _ := pico.BusVoltage()
+	if math.Abs(idleV-emu.BatteryVolts) > 0.05 {
+		t.Fatalf("Unexpected idle voltage %.2f", idleV)
+	}
+	if err := pico.SetMotorSpeeds(RPSToMotorSpeed(5), RPSToMotorSpeed(5), RPSToMotorSpeed(5), RPSToMotorSpeed(5)); err != nil {
+		t.Fatal(err)
+	}
+	v, _ := pico.BusVoltage()
+	a, _ := pico.CurrentAmps()
+	if v >= idleV || math.Abs(a-3.1) > 0.01 {
+		t.Fatalf("Expected voltage to sag under load, got %.2fV %.2fA", v, a)
+	}
+
+	clock.Advance(5 * time.Minute)
+	_ = pico.SetMotorSpeeds(RPSToMotorSpeed(5), RPSToMotorSpeed(5), RPSToMotorSpeed(5), RPSToMotorSpeed(5))
+	if c, _ := pico.TemperatureC(); c < 80 {
+		t.Fatalf("Expected board to heat up under load, got %.1fC", c)
+	}
+}
@@ -0,0 +1,64 @@
+package picobldc
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// The kinds of bus error, by how we recover from them.  BusError matches one of these with
+// errors.Is.
+var (
+	// ErrTransient is a one-off glitch, typically noise from the motors.  Retrying straight away
+	// usually works.
+	ErrTransient = errors.New("transient I2C error")
+	// ErrDeviceMissing means that the Pico didn't acknowledge its address: it's rebooting, has lost
+	// power or has been unplugged.
+	ErrDeviceMissing = errors.New("Pico-BLDC not responding")
+	// ErrBusStuck means that the transaction timed out, usually because a device is holding SDA low.
+	ErrBusStuck = errors.New("I2C bus stuck")
+)
+
+// retryPolicy is how hard writeWithRetries tries for each kind of error.  The I2C loop runs every
+// 25ms and the Pico's watchdog stops the motors after 1s so there's no point trying for long; the
+// loop's recovery path takes over.
+type retryPolicy struct {
+	tries int
+	delay time.Duration
+}
+
+var retryPolicies = map[error]retryPolicy{
+	ErrTransient:     {tries: 10, delay: time.Millisecond},
+	ErrDeviceMissing: {tries: 3, delay: 5 * time.Millisecond},
+	ErrBusStuck:      {tries: 2, delay: 10 * time.Millisecond},
+}
+
+// BusError is returned when a transaction with the Pico fails, after retrying if it was a write.
+type BusError struct {
+	Op    string // "read" or "write"
+	Reg   Register
+	Tries int
+	Kind  error // ErrTransient, ErrDeviceMissing or ErrBusStuck.
+	Err   error // From the bus.
+}
+
+func (e *BusError) Error() string {
+	return fmt.Sprintf("failed to %s Pico-BLDC register %d after %d tries: %v: %v", e.Op, e.Reg, e.Tries, e.Kind, e.Err)
+}
+
+func (e *BusError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// classifyBusError maps an error from the kernel's I2C driver to one of our kinds.
+func classifyBusError(err error) error {
+	switch {
+	case errors.Is(err, syscall.ENXIO), errors.Is(err, syscall.EREMOTEIO):
+		// No ACK for the address.
+		return ErrDeviceMissing
+	case errors.Is(err, syscall.ETIMEDOUT):
+		return ErrBusStuck
+	}
+	return ErrTransient
+}
@@ -7,7 +7,7 @@ import (
 	"math"
 	"time"
 
-	"golang.org/x/exp/io/i2c"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/i2cbus"
 )
 
 // DEVICE_REG_MODE1 = 0x00
@@ -28,7 +28,9 @@ const (
 	PicoAddr = 0x42
 )
 
-var calibration = [4]uint16{
+// defaultCalibration is for the motors that the robot was built with.  Each robot's own calibration
+// lives in CalibrationFile.
+var defaultCalibration = [NumMotors]uint16{
 	0x01a3,
 	0x0386,
 	0x01d5,
@@ -112,27 +114,38 @@ type Interface interface {
 }
 
 type PicoBLDC struct {
-	dev *i2c.Device
+	bus i2cbus.Bus
+	dev i2cbus.Device
+
+	// calibration is written to the Pico if it has lost its own.
+	calibration [NumMotors]uint16
 
 	lastConfigWord  uint16
 	lastConfigTime  time.Time
 	watchdogEnabled bool
+	watchdogTimeout uint16 // ms
 }
 
 func Dummy() Interface {
 	return &dummyPico{}
 }
 
-var i2cBus = &i2c.Devfs{Dev: "/dev/i2c-1"}
+const DefaultDeviceFile = "/dev/i2c-1"
 
 func New() (*PicoBLDC, error) {
-	dev, err := i2c.Open(i2cBus, PicoAddr)
+	return NewOnBus(i2cbus.Devfs(DefaultDeviceFile))
+}
+
+func NewOnBus(bus i2cbus.Bus) (*PicoBLDC, error) {
+	dev, err := i2cbus.Open(bus, PicoAddr)
 	if err != nil {
 		return nil, err
 	}
 
 	pico := &PicoBLDC{
-		dev: dev,
+		bus:         bus,
+		dev:         dev,
+		calibration: loadCalibrationOrDefault(),
 	}
 
 	return pico, nil
@@ -140,8 +153,22 @@ func New() (*PicoBLDC, error) {
 
 var _ Interface = (*PicoBLDC)(nil)
 
+// Reset stops the motors and clears any motor driver fault.  If the Pico has rebooted, it will have
+// lost its configuration so Reset writes it all again: calibration (if the registers are empty),
+// watchdog timeout and control word.  The motors restart on the next SetMotorSpeeds.
 func (p *PicoBLDC) Reset() error {
-	return p.maybeConfigure(true, false, true)
+	if p.watchdogEnabled {
+		// Arm the timeout before the control word enables the watchdog; a timeout of 0 would
+		// expire straight away.
+		if err := p.writeReg(RegWatchdogTimeout, p.watchdogTimeout); err != nil {
+			return err
+		}
+	}
+	p.lastConfigWord = 0
+	if err := p.maybeConfigure(true, false, false); err != nil {
+		return err
+	}
+	return p.ClearStatus(RegStatusWatchdogExpired)
 }
 
 var ErrNotReady = errors.New("Pico-BLDC not ready")
@@ -173,11 +200,19 @@ func (p *PicoBLDC) SetWatchdog(timeout time.Duration) error {
 	}
 
 	p.watchdogEnabled = true
+	p.watchdogTimeout = uint16(ms)
 	return p.maybeConfigure(false, false, false)
 }
 
+// calibrationTimeout is how long to wait for the Pico to finish calibrating.
+const calibrationTimeout = 30 * time.Second
+
+var ErrCalibrationFault = errors.New("Pico-BLDC faulted while calibrating, are the motors powered?")
+
+// Calibrate stops the motors and runs the Pico's calibration, waiting for it to finish.  It fails if
+// the motor drivers fault or if the calibration doesn't finish within calibrationTimeout.
 func (p *PicoBLDC) Calibrate() error {
-	return p.maybeConfigure(true, false, false)
+	return p.maybeConfigure(true, false, true)
 }
 
 func (p *PicoBLDC) SetMotorSpeeds(frontLeft, frontRight, backLeft, backRight int16) error {
@@ -204,26 +239,28 @@ func (p *PicoBLDC) Close() error {
 	return p.dev.Close()
 }
 
+// writeWithRetries writes to the Pico, reopening the device and retrying according to the kind of
+// error.  If it gives up, it returns a *BusError.
 func (p *PicoBLDC) writeWithRetries(data []byte) error {
-	var err error
-	for tries := 0; tries < 20; tries++ {
-		err = p.dev.Write(data)
+	for tries := 1; ; tries++ {
+		err := p.dev.Write(data)
 		if err == nil {
-			if tries > 0 {
+			if tries > 1 {
 				fmt.Println("Successfully programmed Pico-BLDC after retries")
 			}
 			return nil
 		}
+		kind := classifyBusError(err)
+		if tries >= retryPolicies[kind].tries {
+			return &BusError{Op: "write", Reg: Register(data[0]), Tries: tries, Kind: kind, Err: err}
+		}
 		fmt.Println("Failed to write to Pico-BLDC:", err)
-		time.Sleep(1 * time.Millisecond)
+		time.Sleep(retryPolicies[kind].delay)
 		_ = p.dev.Close()
-		dev, err := i2c.Open(i2cBus, PicoAddr)
-		if err != nil {
-			continue
+		if dev, err := i2cbus.Open(p.bus, PicoAddr); err == nil {
+			p.dev = dev
 		}
-		p.dev = dev
 	}
-	panic("Failed to write to Pico-BLDC")
 }
 
 func (p *PicoBLDC) maybeConfigure(resetMotorSpeeds bool, enableMotors bool, forceCalibration bool) error {
@@ -256,7 +293,7 @@ func (p *PicoBLDC) maybeConfigure(resetMotorSpeeds bool, enableMotors bool, forc
 		}
 		if calib == 0 {
 			// Calibration register empty, apply calibration.
-			for i, w := range calibration {
+			for i, w := range p.calibration {
 				err := p.writeReg(RegMot0Calib+Register(i), w)
 				if err != nil {
 					return fmt.Errorf("failed to write calibration register: %w", err)
@@ -272,21 +309,24 @@ func (p *PicoBLDC) maybeConfigure(resetMotorSpeeds bool, enableMotors bool, forc
 	if configWord&RegCtrlDoCalib != 0 {
 		// Wait for calibration to finish.
 		var lastPrint time.Time
+		deadline := time.Now().Add(calibrationTimeout)
 		for {
 			status, err := p.readReg(RegStatus)
 			if err != nil {
 				fmt.Printf("Pico: failed to read status register: %v\n", err)
-			}
-			if status&uint16(RegStatusCalibDone) != 0 {
+			} else if status&uint16(RegStatusCalibDone) != 0 {
 				break
+			} else if status&uint16(RegStatusFault) != 0 {
+				return ErrCalibrationFault
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("Pico-BLDC calibration didn't finish within %v", calibrationTimeout)
 			}
 			if time.Since(lastPrint) > time.Second {
 				fmt.Printf("Waiting for calibration to finish... Status=%x\n", status)
-				if status&uint16(RegStatusFault) != 0 {
-					fmt.Println("Motor fault detected, are motors powered?")
-				}
 				lastPrint = time.Now()
 			}
+			time.Sleep(10 * time.Millisecond)
 		}
 
 		fmt.Printf("Calibration words:")
@@ -353,6 +393,26 @@ func (p *PicoBLDC) Status() (StatusFlag, error) {
 	return StatusFlag(raw), nil
 }
 
+// ClearStatus acknowledges the given status flags.
+func (p *PicoBLDC) ClearStatus(flags StatusFlag) error {
+	return p.writeReg(RegStatus, uint16(flags))
+}
+
+// FaultCount returns the number of motor driver faults since the Pico booted.
+func (p *PicoBLDC) FaultCount() (uint16, error) {
+	return p.readReg(RegFaultCount)
+}
+
+// WatchdogTimeout reads back the watchdog timeout.  It reads 0 if the Pico has rebooted since
+// SetWatchdog.
+func (p *PicoBLDC) WatchdogTimeout() (time.Duration, error) {
+	raw, err := p.readReg(RegWatchdogTimeout)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(raw) * time.Millisecond, nil
+}
+
 func (p *PicoBLDC) writeReg(reg Register, value uint16) error {
 	return p.writeWithRetries([]byte{byte(reg), byte(value >> 8), byte(value)})
 }
@@ -361,7 +421,7 @@ func (p *PicoBLDC) readReg(reg Register) (uint16, error) {
 	var buf [2]byte
 	err := p.dev.ReadReg(byte(reg), buf[:])
 	if err != nil {
-		return 0, err
+		return 0, &BusError{Op: "read", Reg: reg, Tries: 1, Kind: classifyBusError(err), Err: err}
 	}
 	return binary.BigEndian.Uint16(buf[:]), nil
 }
@@ -0,0 +1,141 @@
+package picobldc
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/i2cbus"
+)
+
+func newFakePico(t *testing.T) (*PicoBLDC, *i2cbus.Registers, *i2cbus.Fake) {
+	bus := i2cbus.NewFake()
+	regs := i2cbus.NewRegisters(2)
+	bus.Attach(PicoAddr, regs)
+	pico, err := NewOnBus(bus)
+	if err != nil {
+		t.Fatalf("Failed to open fake Pico: %v", err)
+	}
+	return pico, regs, bus
+}
+
+func calibWrites(regs *i2cbus.Registers) (n int) {
+	for _, w := range regs.Writes() {
+		if w.Reg >= byte(RegMot0Calib) && w.Reg <= byte(RegMot3Calib) {
+			n++
+		}
+	}
+	return
+}
+
+func TestFirstConfigureAppliesDefaultCalibration(t *testing.T) {
+	pico, regs, _ := newFakePico(t)
+
+	if err := pico.SetMotorSpeeds(100, 200, 300, 400); err != nil {
+		t.Fatalf("SetMotorSpeeds failed: %v", err)
+	}
+
+	for i, c := range defaultCalibration {
+		if v := regs.Uint16(byte(RegMot0Calib) + byte(i)); v != c {
+			t.Errorf("Calibration register %d = %04x, expected %04x", i, v, c)
+		}
+	}
+	if v := regs.Uint16(byte(RegCtrl)); v != RegCtrlEnableI2CControl|RegCtrlRun {
+		t.Errorf("Unexpected control word %04x", v)
+	}
+	for m, expected := range map[int]int16{FrontLeft: 100, FrontRight: 200, BackLeft: 300, BackRight: 400} {
+		if v := int16(regs.Uint16(byte(RegMot0V) + byte(m))); v != expected {
+			t.Errorf("Motor %d speed = %d, expected %d", m, v, expected)
+		}
+	}
+}
+
+func TestConfigureKeepsExistingCalibration(t *testing.T) {
+	pico, regs, _ := newFakePico(t)
+	regs.SetUint16(byte(RegMot3Calib), 0x0123)
+
+	if err := pico.SetMotorSpeeds(0, 0, 0, 0); err != nil {
+		t.Fatalf("SetMotorSpeeds failed: %v", err)
+	}
+	if n := calibWrites(regs); n != 0 {
+		t.Fatalf("Expected existing calibration to be left alone, got %d calibration writes", n)
+	}
+}
+
+func TestForcedCalibrationWaitsForCalibDone(t *testing.T) {
+	pico, regs, _ := newFakePico(t)
+	regs.SetUint16(byte(RegMot3Calib), 0x0123)
+	calibrations := 0
+	regs.OnWrite(func(w i2cbus.RegWrite) {
+		if w.Reg == byte(RegCtrl) && regs.Uint16(w.Reg)&RegCtrlDoCalib != 0 {
+			// Calibration finishes immediately.
+			calibrations++
+			regs.SetUint16(byte(RegStatus), uint16(RegStatusCalibDone))
+		}
+	})
+
+	if err := pico.maybeConfigure(true, false, true); err != nil {
+		t.Fatalf("Calibration failed: %v", err)
+	}
+	if calibrations != 1 {
+		t.Fatalf("Expected one calibration, got %d", calibrations)
+	}
+	if v := regs.Uint16(byte(RegCtrl)); v&RegCtrlReset == 0 {
+		t.Errorf("Expected motors to be reset while calibrating, control word %04x", v)
+	}
+	writes := regs.Writes()
+	if last := writes[len(writes)-1]; last.Reg != byte(RegStatus) {
+		t.Errorf("Expected calib done flag to be acknowledged, last write was %v", last)
+	}
+}
+
+func TestWritesRetryTransientFaults(t *testing.T) {
+	pico, regs, bus := newFakePico(t)
+	if err := pico.SetMotorSpeeds(0, 0, 0, 0); err != nil {
+		t.Fatalf("SetMotorSpeeds failed: %v", err)
+	}
+
+	bus.InjectFaults(PicoAddr, i2cbus.ErrTransient, i2cbus.ErrTransient)
+	if err := pico.SetMotorSpeeds(1024, 0, 0, 0); err != nil {
+		t.Fatalf("SetMotorSpeeds failed: %v", err)
+	}
+	if v := regs.Uint16(byte(RegMot0V) + FrontLeft); v != 1024 {
+		t.Fatalf("Expected speed to be written after retries, got %d", v)
+	}
+}
+
+func TestWriteFailuresReturnTypedErrors(t *testing.T) {
+	pico, _, bus := newFakePico(t)
+	if err := pico.SetMotorSpeeds(0, 0, 0, 0); err != nil {
+		t.Fatalf("SetMotorSpeeds failed: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		breakBus func()
+		expected error
+	}{
+		{"noisy", func() {
+			for i := 0; i < 20; i++ {
+				bus.InjectFaults(PicoAddr, i2cbus.ErrTransient)
+			}
+		}, ErrTransient},
+		{"unplugged", func() { bus.SetStuck(false); bus.Detach(PicoAddr) }, ErrDeviceMissing},
+		{"stuck", func() { bus.SetStuck(true) }, ErrBusStuck},
+	} {
+		tc.breakBus()
+		err := pico.SetMotorSpeeds(1024, 0, 0, 0)
+		if !errors.Is(err, tc.expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, err)
+		}
+		var busErr *BusError
+		if !errors.As(err, &busErr) || busErr.Op != "write" || busErr.Tries != retryPolicies[tc.expected].tries {
+			t.Errorf("%s: unexpected error details %#v", tc.name, err)
+		}
+	}
+
+	// The Pi's I2C driver reports a missing device as EREMOTEIO.
+	if kind := classifyBusError(syscall.EREMOTEIO); kind != ErrDeviceMissing {
+		t.Errorf("EREMOTEIO classified as %v", kind)
+	}
+}
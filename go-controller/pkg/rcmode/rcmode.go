@@ -3,6 +3,7 @@ package rcmode
 import (
 	"context"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/challengemode"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder/angle"
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/screen"
 	"log"
 	"math"
@@ -16,8 +17,13 @@ import (
 	"fmt"
 
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/joystick"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/tunable"
 )
 
+// NoteFieldOriented is shown while field-oriented driving is on, so that the driver knows which way
+// the right stick will move the bot.
+const NoteFieldOriented = "FIELD DRIVE"
+
 type RCMode struct {
 	name         string
 	startupSound string
@@ -30,6 +36,9 @@ type RCMode struct {
 	cancel         context.CancelFunc
 	stopWG         sync.WaitGroup
 	joystickEvents chan *joystick.Event
+
+	// The yaw-rate gains can be tuned with the D-pad while R1 is held.
+	tunables tunable.Tunables
 }
 
 func New(
@@ -45,6 +54,7 @@ func New(
 		name:            name,
 		startupSound:    startupSound,
 	}
+	hardware.AddHeadingGainTunables(&r.tunables, hw, hardware.RelativeGains)
 	return r
 }
 
@@ -66,6 +76,10 @@ func (m *RCMode) Start(ctx context.Context) {
 func (m *RCMode) Stop() {
 	m.cancel()
 	m.stopWG.Wait()
+
+	for _, t := range m.tunables.All {
+		fmt.Println("RCMode: Tunable:", t.Name, "=", t.Value)
+	}
 }
 
 func (m *RCMode) loop(ctx context.Context) {
@@ -81,14 +95,39 @@ func (m *RCMode) loop(ctx context.Context) {
 
 	var leftStickX, leftStickY, rightStickX, rightStickY int16
 	var mix = MixAggressive
+	// In field-oriented driving, the right stick moves the bot relative to the way it was facing when
+	// field-oriented driving was switched on, whichever way it's facing now.
+	var fieldOriented bool
+	var fieldForward angle.PlusMinus180
+	defer screen.ClearNotice(NoteFieldOriented)
+	var tuning bool
 
 	fmt.Println("RCMode taking control of motors")
 	motorController := m.hardware.StartYawAndThrottleMode()
 	defer m.hardware.StopMotorControl()
+	m.hardware.UseMotionLimits(hardware.LimitsRC)
+
+	drive := func() {
+		lStickY := leftStickY
+		if fieldOriented {
+			// The left stick only turns the bot.
+			lStickY = 0
+		}
+		yaw, throttle, translation := mix(leftStickX, lStickY, rightStickX, rightStickY)
+		if fieldOriented {
+			heading := m.hardware.CurrentHeading().Sub(fieldForward)
+			throttle, translation = FieldToRobot(throttle, translation, heading.Float())
+		}
+		motorController.SetYawAndThrottle(-yaw, throttle, translation)
+	}
 
 	screenEnabled := true
 	screenTicker := time.NewTicker(200 * time.Millisecond)
 	defer screenTicker.Stop()
+	// In field-oriented driving, the bot's heading changes the wheel speeds for the same stick
+	// positions so we keep updating them even if the sticks don't move.
+	fieldTicker := time.NewTicker(20 * time.Millisecond)
+	defer fieldTicker.Stop()
 
 	showAimC := make(chan struct{}, 1)
 	go func() {
@@ -112,6 +151,25 @@ func (m *RCMode) loop(ctx context.Context) {
 			fmt.Println("RCMode context done")
 			return
 		case event := <-m.joystickEvents:
+			if tuning && event.Type == joystick.EventTypeAxis {
+				// While tuning, the D-pad is ours rather than the servo controller's.
+				switch event.Number {
+				case joystick.AxisDPadX:
+					if event.Value > 0 {
+						m.tunables.SelectNext()
+					} else if event.Value < 0 {
+						m.tunables.SelectPrev()
+					}
+					continue
+				case joystick.AxisDPadY:
+					if event.Value < 0 {
+						m.tunables.Current().Add(1)
+					} else if event.Value > 0 {
+						m.tunables.Current().Add(-1)
+					}
+					continue
+				}
+			}
 			switch event.Type {
 			case joystick.EventTypeAxis:
 				switch event.Number {
@@ -134,21 +192,41 @@ func (m *RCMode) loop(ctx context.Context) {
 						fmt.Println("Aggressive mode")
 						mix = MixAggressive
 					}
+				case joystick.ButtonR1:
+					tuning = event.Value == 1
+					if tuning {
+						fmt.Println("Tuning yaw-rate gains; tunable", m.tunables.Current().Name, "=", m.tunables.Current().Get())
+					}
 				case joystick.ButtonTriangle:
 					if event.Value == 1 {
 						screenEnabled = !screenEnabled
 						screen.SetEnabled(screenEnabled)
 					}
+				case joystick.ButtonL1:
+					if event.Value == 1 {
+						fieldOriented = !fieldOriented
+						if fieldOriented {
+							fieldForward = m.hardware.CurrentHeading()
+							fmt.Printf("Field-oriented driving, forward is heading %.1f\n", fieldForward.Float())
+							screen.SetNotice(NoteFieldOriented, screen.LevelInfo)
+						} else {
+							fmt.Println("Robot-oriented driving")
+							screen.ClearNotice(NoteFieldOriented)
+						}
+					}
 				}
 			}
 
 			m.servoController.OnJoystickEvent(event)
-			yaw, throttle, translation := mix(leftStickX, leftStickY, rightStickX, rightStickY)
-			motorController.SetYawAndThrottle(-yaw, throttle, translation)
+			drive()
 
 			//m.hardware.SetServo(8, clamp(0.3+throttle/2-yaw/3, 0.2, 1))  // 0 is arm down, 1 is arm up
 			//m.hardware.SetServo(10, clamp(0.7-throttle/2-yaw/3, 0, 0.8)) // 0 is arm up, 1 is arm down
 			//m.hardware.SetServo(9, clamp(-yaw/2+0.5, 0.25, 0.75))        // 0.25 is right, 0.75 is left
+		case <-fieldTicker.C:
+			if fieldOriented {
+				drive()
+			}
 		case <-screenTicker.C:
 			if screenEnabled {
 				continue
@@ -198,6 +276,16 @@ func MixAggressive(lStickX, lStickY, rStickX, rStickY int16) (yaw, throttle, tra
 	return
 }
 
+// FieldToRobot converts a throttle (forwards) and translation (to the left) in the field's frame to
+// the robot's frame, given the robot's heading relative to the field, in degrees anticlockwise.
+func FieldToRobot(throttle, translation, headingDegrees float64) (robotThrottle, robotTranslation float64) {
+	headingRads := headingDegrees * math.Pi / 180
+	sin, cos := math.Sin(headingRads), math.Cos(headingRads)
+	robotThrottle = throttle*cos + translation*sin
+	robotTranslation = translation*cos - throttle*sin
+	return
+}
+
 func applyExpo(value float64, expo float64) float64 {
 	absVal := math.Abs(value)
 	absExpo := math.Pow(absVal, expo)
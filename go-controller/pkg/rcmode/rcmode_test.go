@@ -6,18 +6,47 @@ import (
 )
 
 func TestMix(t *testing.T) {
-	fl, fr, bl, br := MixAggressive(0, 0)
-	if fl != 0 || fr != 0 || bl != 0 || br != 0 {
-		t.Fatalf("Input of 0s should return 0s, not %v, %v, %v, %v", fl, fr, bl, br)
+	yaw, throttle, translation := MixAggressive(0, 0, 0, 0)
+	if yaw != 0 || throttle != 0 || translation != 0 {
+		t.Fatalf("Input of 0s should return 0s, not %v, %v, %v", yaw, throttle, translation)
 	}
 
-	fl, fr, bl, br = MixAggressive(math.MinInt16, 0)
-	if fl != -63 || fr != 63 || bl != -63 || br != 63 {
-		t.Fatalf("Input of full-left returned %v, %v, %v, %v", fl, fr, bl, br)
+	yaw, throttle, translation = MixAggressive(math.MinInt16, 0, 0, 0)
+	if math.Abs(yaw+1) > 0.001 || throttle != 0 || translation != 0 {
+		t.Fatalf("Input of full-left returned %v, %v, %v", yaw, throttle, translation)
 	}
 
-	fl, fr, bl, br = MixAggressive(math.MaxInt16, 0)
-	if fl != 63 || fr != -63 || bl != 63 || br != -63 {
-		t.Fatalf("Input of full-left returned %v, %v, %v, %v", fl, fr, bl, br)
+	yaw, throttle, translation = MixAggressive(math.MaxInt16, 0, 0, 0)
+	if yaw != 1 || throttle != 0 || translation != 0 {
+		t.Fatalf("Input of full-right returned %v, %v, %v", yaw, throttle, translation)
+	}
+}
+
+func TestFieldToRobot(t *testing.T) {
+	for _, tc := range []struct {
+		throttle, translation, heading  float64
+		robotThrottle, robotTranslation float64
+	}{
+		// Field forward, with the bot facing various ways.
+		{1, 0, 0, 1, 0},
+		{1, 0, 90, 0, -1},
+		{1, 0, 180, -1, 0},
+		{1, 0, -90, 0, 1},
+		// Field left.
+		{0, 1, 0, 0, 1},
+		{0, 1, 90, 1, 0},
+		{0, 1, -90, -1, 0},
+		// Headings outside (-180, 180] wrap around.
+		{1, 0, 270, 0, 1},
+		{1, 0, -270, 0, -1},
+		{1, 0, 450, 0, -1},
+		// Diagonal, facing 45 degrees left of field forward; the stick is then dead ahead.
+		{0.5, 0.5, 45, math.Sqrt2 / 2, 0},
+	} {
+		robotThrottle, robotTranslation := FieldToRobot(tc.throttle, tc.translation, tc.heading)
+		if math.Abs(robotThrottle-tc.robotThrottle) > 1e-9 || math.Abs(robotTranslation-tc.robotTranslation) > 1e-9 {
+			t.Errorf("FieldToRobot(%v, %v, %v) = %v, %v; expected %v, %v", tc.throttle, tc.translation,
+				tc.heading, robotThrottle, robotTranslation, tc.robotThrottle, tc.robotTranslation)
+		}
 	}
 }
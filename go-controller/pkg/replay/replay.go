@@ -0,0 +1,385 @@
+// Package replay feeds IMU reports and wheel rotations from a telemetry log back through the heading
+// holder loops and compares the motor commands that they produce with the ones that were recorded.
+// It lets us try out changes to the control loops against real runs.
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/bno08x"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder/angle"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/telemetry"
+)
+
+// Load reads all the entries from a telemetry log.  A log that was cut off mid-record is returned up
+// to the last complete record.
+func Load(path string) ([]telemetry.Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := telemetry.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	var entries []telemetry.Entry
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			fmt.Println("Replay: log truncated, ignoring the partial record at the end")
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+}
+
+// Segment is the part of a log covering one run of a heading holder loop.
+type Segment struct {
+	Start   telemetry.Entry // The HeadingHolderStart record.
+	Entries []telemetry.Entry
+}
+
+func (s Segment) Relative() bool {
+	return s.Start.Record.(telemetry.HeadingHolderStart).Relative
+}
+
+func (s Segment) String() string {
+	name := "heading"
+	if s.Relative() {
+		name = "yaw-rate"
+	}
+	d := time.Duration(0)
+	if len(s.Entries) > 0 {
+		d = s.Entries[len(s.Entries)-1].Time.Sub(s.Start.Time)
+	}
+	return fmt.Sprintf("controller starting in %s mode at %s for %v", name, s.Start.Time.Format("15:04:05.000"), d.Round(time.Millisecond))
+}
+
+// Segments splits a log into its heading holder runs.  A segment ends when the loop stops or at a mode
+// switch.  Setpoints can be sent to a heading holder before its loop has started so any that arrive
+// between runs are carried over to the next segment.
+func Segments(entries []telemetry.Entry) []Segment {
+	var segments []Segment
+	var current *Segment
+	var pendingSetpoints []telemetry.Entry
+	for _, e := range entries {
+		switch e.Record.(type) {
+		case telemetry.HeadingHolderStart:
+			segments = append(segments, Segment{Start: e, Entries: pendingSetpoints})
+			current = &segments[len(segments)-1]
+			pendingSetpoints = nil
+			continue
+		case telemetry.HeadingHolderStop:
+			current = nil
+			continue
+		case telemetry.Mode:
+			current = nil
+			pendingSetpoints = nil
+			continue
+		case telemetry.SetHeading, telemetry.SetHeadingWithProfile, telemetry.AddHeadingDelta, telemetry.SetThrottleWithAngle,
+			telemetry.SetYawAndThrottle, telemetry.SetMotionLimits, telemetry.SetHeadingGains:
+			if current == nil {
+				pendingSetpoints = append(pendingSetpoints, e)
+				continue
+			}
+		}
+		if current != nil {
+			current.Entries = append(current.Entries, e)
+		}
+	}
+	for i := range segments {
+		// Records are queued from several goroutines so they can be slightly out of order in the log.
+		sort.SliceStable(segments[i].Entries, func(a, b int) bool {
+			return segments[i].Entries[a].Time.Before(segments[i].Entries[b].Time)
+		})
+	}
+	return segments
+}
+
+// Command is a motor command produced in response to one IMU report, along with the command that the
+// robot sent at that point in the recording.
+type Command struct {
+	Time        time.Time
+	Produced    telemetry.MotorSpeeds
+	Recorded    telemetry.MotorSpeeds
+	HasRecorded bool
+}
+
+// Diff returns the largest difference between the produced and recorded speeds for any motor.
+func (c Command) Diff() int {
+	if !c.HasRecorded {
+		return 0
+	}
+	p, r := c.Produced, c.Recorded
+	diff := 0
+	for _, d := range []int{
+		int(p.FrontLeft) - int(r.FrontLeft),
+		int(p.FrontRight) - int(r.FrontRight),
+		int(p.BackLeft) - int(r.BackLeft),
+		int(p.BackRight) - int(r.BackRight),
+	} {
+		if d < 0 {
+			d = -d
+		}
+		diff = max(diff, d)
+	}
+	return diff
+}
+
+type Result struct {
+	Commands []Command
+}
+
+func (r Result) MaxDiff() int {
+	m := 0
+	for _, c := range r.Commands {
+		m = max(m, c.Diff())
+	}
+	return m
+}
+
+// Mismatches returns the commands that differ from the recording by more than tolerance.
+func (r Result) Mismatches(tolerance int) []Command {
+	var cmds []Command
+	for _, c := range r.Commands {
+		if c.Diff() > tolerance {
+			cmds = append(cmds, c)
+		}
+	}
+	return cmds
+}
+
+// Run replays a segment through a fresh controller, starting in the segment's mode.  The loop is
+// driven in lock step: each IMU report is only handed over once the loop has responded to the previous
+// one, and setpoint calls are applied in between, just as they were on the robot.
+func Run(seg Segment) Result {
+	start := seg.Start.Record.(telemetry.HeadingHolderStart)
+	imu := newFakeIMU(imuReport(seg.Start.Time, start.Initial))
+	motors := &fakeMotors{commands: make(chan telemetry.MotorSpeeds)}
+	zero := fixedReference(angle.FromFloat(start.ZeroYaw))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	hh := headingholder.NewController(motors)
+	hh.IMU = imu
+	hh.Reference = zero
+	// Logs from before the heading holders were merged into one controller relied on each holder's
+	// defaults.  Newer logs record the limits and gains, and any heading, before the loop starts, so
+	// they override these.
+	if start.Relative {
+		hh.SetLimits(headingholder.RCLimits())
+		hh.SetGains(headingholder.DefaultRelativeGains())
+	} else {
+		hh.SetHeading(0)
+	}
+	wg.Add(1)
+	go hh.Loop(ctx, &wg)
+
+	var result Result
+	for _, e := range setpointsFirst(seg.Entries) {
+		switch r := e.Record.(type) {
+		case telemetry.IMU:
+			imu.deliver(imuReport(e.Time, r))
+			result.Commands = append(result.Commands, Command{
+				Time:     e.Time,
+				Produced: <-motors.commands,
+			})
+		case telemetry.MotorSpeeds:
+			// Match up with the most recent report, if the loop hasn't already responded to it.
+			if n := len(result.Commands); n > 0 && !result.Commands[n-1].HasRecorded {
+				result.Commands[n-1].Recorded = r
+				result.Commands[n-1].HasRecorded = true
+			}
+		case telemetry.Rotations:
+			motors.setRotations(r)
+		case telemetry.SetHeading:
+			hh.SetHeading(r.Heading)
+		case telemetry.SetHeadingWithProfile:
+			hh.SetHeadingWithProfile(r.Heading, r.MaxRate, r.MaxAccel)
+		case telemetry.AddHeadingDelta:
+			hh.AddHeadingDelta(r.Delta)
+		case telemetry.SetThrottleWithAngle:
+			hh.SetThrottleWithAngle(r.ThrottleMMPerS, r.Angle)
+		case telemetry.SetYawAndThrottle:
+			hh.SetYawAndThrottle(r.YawRate, r.Throttle, r.Translation)
+		case telemetry.SetHeadingGains:
+			hh.SetGains(headingholder.Gains{Kp: r.Kp, Ki: r.Ki, Kd: r.Kd, MaxIntegral: r.MaxIntegral, MaxD: r.MaxD})
+		case telemetry.SetMotionLimits:
+			hh.SetLimits(headingholder.MotionLimits(r))
+		}
+	}
+
+	// Stop the loop; it may need one more report to notice.
+	cancel()
+	motors.stop()
+	imu.close()
+	wg.Wait()
+
+	return result
+}
+
+// setpointsFirst moves setpoints that were recorded after an IMU report but before the loop's response
+// to it ahead of the report.  The loop reads its setpoints a little after the report arrives, so such
+// setpoints were already in effect for that iteration.
+func setpointsFirst(entries []telemetry.Entry) []telemetry.Entry {
+	var ordered []telemetry.Entry
+	var held []telemetry.Entry // An IMU report and whatever followed it, until the loop responded.
+	flush := func() {
+		ordered = append(ordered, held...)
+		held = nil
+	}
+	for _, e := range entries {
+		switch e.Record.(type) {
+		case telemetry.IMU:
+			flush()
+			held = append(held, e)
+		case telemetry.MotorSpeeds:
+			held = append(held, e)
+			flush()
+		case telemetry.SetHeading, telemetry.SetHeadingWithProfile, telemetry.AddHeadingDelta, telemetry.SetThrottleWithAngle,
+			telemetry.SetYawAndThrottle, telemetry.SetMotionLimits, telemetry.SetHeadingGains:
+			ordered = append(ordered, e)
+		default:
+			if held != nil {
+				held = append(held, e)
+			} else {
+				ordered = append(ordered, e)
+			}
+		}
+	}
+	flush()
+	return ordered
+}
+
+func imuReport(t time.Time, r telemetry.IMU) bno08x.IMUReport {
+	return bno08x.IMUReport{
+		Time:   t,
+		Index:  r.Index,
+		Yaw:    r.Yaw,
+		Pitch:  r.Pitch,
+		Roll:   r.Roll,
+		XAccel: r.XAccel,
+		YAccel: r.YAccel,
+		ZAccel: r.ZAccel,
+	}
+}
+
+type fixedReference angle.PlusMinus180
+
+func (r fixedReference) ZeroYaw() angle.PlusMinus180 {
+	return angle.PlusMinus180(r)
+}
+
+// fakeIMU hands out recorded reports one at a time.
+type fakeIMU struct {
+	lock    sync.Mutex
+	cond    *sync.Cond
+	current bno08x.IMUReport
+	pending *bno08x.IMUReport
+	closed  bool
+}
+
+func newFakeIMU(initial bno08x.IMUReport) *fakeIMU {
+	i := &fakeIMU{current: initial}
+	i.cond = sync.NewCond(&i.lock)
+	return i
+}
+
+func (i *fakeIMU) CurrentReport() bno08x.IMUReport {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.current
+}
+
+func (i *fakeIMU) WaitForReportAfter(t time.Time) bno08x.IMUReport {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	for i.pending == nil {
+		if i.closed {
+			// Make up a report so that the loop can go round and see that it has been cancelled.
+			r := i.current
+			r.Time = t.Add(bno08x.ReportInterval)
+			return r
+		}
+		i.cond.Wait()
+	}
+	i.current = *i.pending
+	i.pending = nil
+	return i.current
+}
+
+func (i *fakeIMU) deliver(r bno08x.IMUReport) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.pending = &r
+	i.cond.Broadcast()
+}
+
+func (i *fakeIMU) close() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.closed = true
+	i.cond.Broadcast()
+}
+
+// fakeMotors captures the loop's motor commands and serves up recorded wheel rotations.
+type fakeMotors struct {
+	commands chan telemetry.MotorSpeeds
+
+	lock      sync.Mutex
+	stopped   bool
+	rotations picobldc.PerMotorVal[float64]
+}
+
+func (m *fakeMotors) SetMotorSpeeds(frontLeft, frontRight, backLeft, backRight int16) error {
+	m.lock.Lock()
+	stopped := m.stopped
+	m.lock.Unlock()
+	if stopped {
+		return nil
+	}
+	m.commands <- telemetry.MotorSpeeds{
+		FrontLeft:  frontLeft,
+		FrontRight: frontRight,
+		BackLeft:   backLeft,
+		BackRight:  backRight,
+	}
+	return nil
+}
+
+func (m *fakeMotors) AccumulatedRotations() picobldc.PerMotorVal[float64] {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.rotations
+}
+
+func (m *fakeMotors) setRotations(r telemetry.Rotations) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.rotations = picobldc.PerMotorVal[float64](r)
+}
+
+// stop makes SetMotorSpeeds stop reporting commands.  Must only be called while the loop is waiting
+// for a report.
+func (m *fakeMotors) stop() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.stopped = true
+}
@@ -85,6 +85,30 @@ func ClearNotice(msg string) {
 	delete(notices, msg)
 }
 
+// State is a copy of what the screen is showing, for other displays such as the dashboard.
+type State struct {
+	Mode        string
+	BusVoltages []float64
+	BusCells    []int
+	Notices     map[string]NoticeLevel
+}
+
+func Snapshot() State {
+	lock.Lock()
+	defer lock.Unlock()
+
+	s := State{
+		Mode:        mode,
+		BusVoltages: append([]float64(nil), busVoltages...),
+		BusCells:    append([]int(nil), busCells...),
+		Notices:     make(map[string]NoticeLevel, len(notices)),
+	}
+	for msg, lvl := range notices {
+		s.Notices[msg] = lvl
+	}
+	return s
+}
+
 func LoopUpdatingScreen(ctx context.Context) {
 	f, err := os.OpenFile("/dev/fb0", os.O_RDWR, 0666)
 	if err != nil {
@@ -0,0 +1,101 @@
+package simbot
+
+import (
+	"math"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/chassis"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/vl53l5cx"
+)
+
+// maxToFRangeMM is the furthest the simulated time-of-flight sensors can see.
+const maxToFRangeMM = 2000
+
+type Wall struct {
+	X1, Y1, X2, Y2 float64
+}
+
+type Arena struct {
+	Walls []Wall
+
+	StartX, StartY float64
+}
+
+// BoxArena returns a rectangular arena with the bot starting in the middle.
+func BoxArena(widthMM, heightMM float64) Arena {
+	return Arena{
+		Walls: []Wall{
+			{0, 0, widthMM, 0},
+			{widthMM, 0, widthMM, heightMM},
+			{widthMM, heightMM, 0, heightMM},
+			{0, heightMM, 0, 0},
+		},
+		StartX: widthMM / 2,
+		StartY: heightMM / 2,
+	}
+}
+
+func DefaultArena() Arena {
+	return BoxArena(1500, 1500)
+}
+
+type tofSensor struct {
+	aheadMM, leftMM float64 // Position relative to the centre of the bot.
+	direction       float64 // Degrees CCW from straight ahead.
+}
+
+const (
+	halfWidth  = chassis.BotWidthMM / 2
+	halfLength = chassis.BotFrontBackWheelCentreDistMM / 2
+)
+
+// Sensors in the same order as hardware.DistanceReadings: clockwise from left-side-rear to
+// right-side-rear.
+var tofSensors = []tofSensor{
+	{-halfLength / 2, halfWidth, 90},
+	{halfLength / 2, halfWidth, 90},
+	{halfLength, halfWidth / 2, 0},
+	{halfLength, -halfWidth / 2, 0},
+	{halfLength / 2, -halfWidth, -90},
+	{-halfLength / 2, -halfWidth, -90},
+}
+
+// DistanceReadingsMM ray-casts from each time-of-flight sensor to the nearest wall.  Readings beyond the
+// sensor's range are reported as vl53l5cx.RangeTooFar, as the real sensors do.
+func (r *Robot) DistanceReadingsMM() []int {
+	x, y, heading := r.Pose()
+	headingRads := heading * math.Pi / 180
+	sin, cos := math.Sin(headingRads), math.Cos(headingRads)
+
+	readings := make([]int, len(tofSensors))
+	for i, s := range tofSensors {
+		sx := x + s.aheadMM*cos - s.leftMM*sin
+		sy := y + s.aheadMM*sin + s.leftMM*cos
+		dirRads := (heading + s.direction) * math.Pi / 180
+		dist := r.arena.castRay(sx, sy, math.Cos(dirRads), math.Sin(dirRads))
+		if dist > maxToFRangeMM {
+			readings[i] = vl53l5cx.RangeTooFar
+			continue
+		}
+		readings[i] = int(dist)
+	}
+	return readings
+}
+
+// castRay returns the distance from (x, y) in direction (dx, dy) to the nearest wall, or +Inf.
+func (a Arena) castRay(x, y, dx, dy float64) float64 {
+	best := math.Inf(1)
+	for _, w := range a.Walls {
+		ex, ey := w.X2-w.X1, w.Y2-w.Y1
+		denom := dx*ey - dy*ex
+		if denom == 0 {
+			continue // Parallel.
+		}
+		// Solve (x, y) + t(dx, dy) = (X1, Y1) + u(ex, ey).
+		t := ((w.X1-x)*ey - (w.Y1-y)*ex) / denom
+		u := ((w.X1-x)*dy - (w.Y1-y)*dx) / denom
+		if t >= 0 && u >= 0 && u <= 1 && t < best {
+			best = t
+		}
+	}
+	return best
+}
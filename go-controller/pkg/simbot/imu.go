@@ -0,0 +1,67 @@
+package simbot
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/bno08x"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/telemetry"
+)
+
+// IMU is a stand-in for the BNO08X that reports the simulated robot's heading.
+type IMU struct {
+	lock       sync.Mutex
+	cond       *sync.Cond
+	lastReport bno08x.IMUReport
+	index      uint8
+}
+
+var _ bno08x.Interface = (*IMU)(nil)
+
+func newIMU() *IMU {
+	i := &IMU{}
+	i.cond = sync.NewCond(&i.lock)
+	return i
+}
+
+func (i *IMU) CurrentReport() bno08x.IMUReport {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.lastReport
+}
+
+func (i *IMU) WaitForReportAfter(t time.Time) bno08x.IMUReport {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	for !i.lastReport.Time.After(t) {
+		i.cond.Wait()
+	}
+	return i.lastReport
+}
+
+func (i *IMU) publish(t time.Time, heading float64) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.index++
+	i.lastReport = ReportForHeading(t, i.index, heading)
+	telemetry.RecordIMU(t, i.lastReport.Telemetry())
+	i.cond.Broadcast()
+}
+
+// ReportForHeading builds an IMU report that bno08x.CalculateRobotYaw maps back to the given heading.
+//
+// The BNO08X is mounted with its Z axis pointing towards the front of the robot, so, with 90 degrees of
+// pitch, the robot's yaw shows up as the IMU's roll.
+func ReportForHeading(t time.Time, index uint8, heading float64) bno08x.IMUReport {
+	roll := heading - 90
+	if roll <= -180 {
+		roll += 360
+	}
+	return bno08x.IMUReport{
+		Time:  t,
+		Index: index,
+		Pitch: 9000,
+		Roll:  int16(math.Round(roll * 100)),
+	}
+}
@@ -0,0 +1,141 @@
+package simbot
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder/angle"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/kinematics"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
+)
+
+const (
+	// StepInterval is how often Run advances the physics.  Matches the IMU report rate so that
+	// each step produces one IMU report.
+	StepInterval = 10 * time.Millisecond
+
+	// motorTimeConstant controls how quickly the simulated wheels reach their commanded speed.
+	motorTimeConstant = 60 * time.Millisecond
+)
+
+// Robot is a kinematic model of the mecanum chassis.  It takes motor speeds in the same format as
+// the Pico-BLDC and integrates them into wheel rotations and a position/heading in the arena.
+//
+// Arena coordinates are in mm with heading in degrees, measured CCW from the positive X axis, matching
+// challengemode's convention.
+type Robot struct {
+	lock sync.Mutex
+
+	arena Arena
+
+	x, y, heading float64
+
+	commandedRPS picobldc.PerMotorVal[float64]
+	wheelRPS     picobldc.PerMotorVal[float64]
+	rotations    picobldc.PerMotorVal[float64]
+
+	imu *IMU
+}
+
+func New(arena Arena) *Robot {
+	return &Robot{
+		arena: arena,
+		x:     arena.StartX,
+		y:     arena.StartY,
+		imu:   newIMU(),
+	}
+}
+
+// SetMotorSpeeds takes speeds in the Pico-BLDC fixed point format.
+func (r *Robot) SetMotorSpeeds(frontLeft, frontRight, backLeft, backRight int16) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.commandedRPS[picobldc.FrontLeft] = float64(frontLeft) * picobldc.SpeedRPSLSB
+	r.commandedRPS[picobldc.FrontRight] = float64(frontRight) * picobldc.SpeedRPSLSB
+	r.commandedRPS[picobldc.BackLeft] = float64(backLeft) * picobldc.SpeedRPSLSB
+	r.commandedRPS[picobldc.BackRight] = float64(backRight) * picobldc.SpeedRPSLSB
+}
+
+func (r *Robot) AccumulatedRotations() picobldc.PerMotorVal[float64] {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.rotations
+}
+
+// WheelRPS returns the speeds that the wheels are actually turning at, and the speeds that they
+// were last commanded to turn at.
+func (r *Robot) WheelRPS() (actual, commanded picobldc.PerMotorVal[float64]) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.wheelRPS, r.commandedRPS
+}
+
+// Pose returns the robot's current position (mm) and heading (degrees CCW).
+func (r *Robot) Pose() (x, y, heading float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.x, r.y, r.heading
+}
+
+// SetPose teleports the robot; wheel speeds are left as they are.
+func (r *Robot) SetPose(x, y, heading float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.x, r.y, r.heading = x, y, heading
+}
+
+func (r *Robot) IMU() *IMU {
+	return r.imu
+}
+
+// Step advances the model by dt.
+func (r *Robot) Step(dt time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	secs := dt.Seconds()
+
+	// First-order lag from commanded to actual wheel speed.
+	alpha := 1 - math.Exp(-secs/motorTimeConstant.Seconds())
+	for m := range r.wheelRPS {
+		r.wheelRPS[m] += (r.commandedRPS[m] - r.wheelRPS[m]) * alpha
+		r.rotations[m] += r.wheelRPS[m] * secs
+	}
+
+	// The model assumes that the real bot behaves exactly as the heading holder expects.
+	v := kinematics.Default().Motion(r.wheelRPS)
+
+	// Integrate using the heading at the midpoint of the step.
+	midHeading := (r.heading + v.YawDegrees*secs/2) * math.Pi / 180
+	sin, cos := math.Sin(midHeading), math.Cos(midHeading)
+	r.x += (v.AheadMM*cos - v.LeftMM*sin) * secs
+	r.y += (v.AheadMM*sin + v.LeftMM*cos) * secs
+	r.heading = angle.FromFloat(r.heading + v.YawDegrees*secs).Float()
+}
+
+// Run steps the model in real time until the context is cancelled, publishing an IMU report for
+// each step.
+func (r *Robot) Run(ctx context.Context) {
+	ticker := time.NewTicker(StepInterval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.Step(now.Sub(last))
+			last = now
+			_, _, heading := r.Pose()
+			r.imu.publish(now, heading)
+		}
+	}
+}
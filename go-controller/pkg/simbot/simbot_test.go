@@ -0,0 +1,74 @@
+package simbot
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/chassis"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/picobldc"
+)
+
+func runFor(r *Robot, d time.Duration) {
+	for t := time.Duration(0); t < d; t += StepInterval {
+		r.Step(StepInterval)
+	}
+}
+
+func TestDriveStraight(t *testing.T) {
+	r := New(BoxArena(4000, 4000))
+	startX, startY, _ := r.Pose()
+
+	// Same mixing as the heading holder: 1 RPS of throttle.
+	one := picobldc.RPSToMotorSpeed(1)
+	r.SetMotorSpeeds(one, -one, one, -one)
+	runFor(r, 2*time.Second)
+
+	x, y, heading := r.Pose()
+	// Allow for the wheels spinning up.
+	if dx := x - startX; math.Abs(dx-2*chassis.WheelCircumMM) > 0.1*chassis.WheelCircumMM {
+		t.Fatalf("Expected to move ~%.0fmm ahead, moved %.0fmm", 2*chassis.WheelCircumMM, dx)
+	}
+	if math.Abs(y-startY) > 0.1 || math.Abs(heading) > 0.1 {
+		t.Fatalf("Expected straight line motion, got y=%.2f heading=%.2f", y-startY, heading)
+	}
+
+	rot := r.AccumulatedRotations()
+	if math.Abs(rot[picobldc.FrontLeft]-(x-startX)/chassis.WheelCircumMM) > 0.01 {
+		t.Fatalf("Wheel rotations %v don't match distance travelled", rot)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	r := New(DefaultArena())
+
+	// All wheels turning the same way spins the bot on the spot.
+	one := picobldc.RPSToMotorSpeed(-0.5)
+	r.SetMotorSpeeds(one, one, one, one)
+	runFor(r, time.Second)
+
+	x, y, heading := r.Pose()
+	if x != 750 || y != 750 {
+		t.Fatalf("Bot should spin on the spot, moved to %.2f, %.2f", x, y)
+	}
+	if heading <= 0 {
+		t.Fatalf("Positive rotation should increase the heading, got %.2f", heading)
+	}
+}
+
+func TestDistanceReadings(t *testing.T) {
+	r := New(BoxArena(1000, 600))
+
+	readings := r.DistanceReadingsMM()
+	if len(readings) != 6 {
+		t.Fatalf("Expected 6 readings, got %v", readings)
+	}
+	// Facing +X from the middle of the box: 500mm to the front wall, 300mm to the sides, less the
+	// sensor offsets.
+	if readings[2] != int(500-halfLength) {
+		t.Fatalf("Unexpected forward reading: %v", readings)
+	}
+	if readings[0] != int(300-halfWidth) || readings[5] != int(300-halfWidth) {
+		t.Fatalf("Unexpected side readings: %v", readings)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/tigerbot-team/tigerbot/go-controller/pkg/hardware"
+	"github.com/tigerbot-team/tigerbot/go-controller/pkg/headingholder"
 
 	"fmt"
 	"sort"
@@ -67,6 +68,7 @@ func New(hw hardware.Interface) *SLSTMode {
 	mm.baseSpeedPct = mm.tunables.Create("Base speed %", 20)
 	mm.topSpeedPct = mm.tunables.Create("Top speed %", 50)
 	mm.speedUpDist = mm.tunables.Create("Speed up dist (mm)", 150)
+	hardware.AddHeadingGainTunables(&mm.tunables, hw, hardware.AbsoluteGains)
 
 	return mm
 }
@@ -157,6 +159,10 @@ func (s *SLSTMode) startSequence() {
 		fmt.Println("SLST: Already running")
 		return
 	}
+	if battery := s.hw.BatteryStatus(); !battery.AutonomousAllowed() {
+		fmt.Println("SLST: Battery too low to start sequence:", battery.Level)
+		return
+	}
 
 	fmt.Println("SLST: Starting sequence...")
 	s.running = true
@@ -180,24 +186,42 @@ func (s *SLSTMode) runSequence(ctx context.Context) {
 
 	var readings hardware.DistanceReadings
 
-	readSensors := func() {
+	// readSensors returns false if there are no new readings, because the sensors have gone stale or
+	// ctx is done.  The filters then still hold the old readings so don't steer by them.
+	readSensors := func() bool {
 		// Read the sensors
 		msg := "SLST readings "
-		readings = s.hw.CurrentDistanceReadings(readings.Revision)
+		newReadings, err := s.hw.WaitForDistanceReadings(ctx, readings.Revision)
+		if err != nil {
+			fmt.Println("SLST: Failed to read sensors:", err)
+			return false
+		}
+		readings = newReadings
 		for j, r := range readings.Readings {
 			prettyPrinted := "-"
-			readingInMM, err := r.DistanceMM, r.Error
-			filters[j].Accumulate(readingInMM, readings.CaptureTime)
-			if readingInMM == vl53l5cx.RangeTooFar {
+			filters[j].Accumulate(r.DistanceMM, r.CaptureTime)
+			switch r.Health {
+			case hardware.ReadingTooFar:
 				prettyPrinted = ">2000mm"
-			} else if err != nil {
+			case hardware.ReadingFailed:
 				prettyPrinted = "<failed>"
-			} else {
-				prettyPrinted = fmt.Sprintf("%dmm", readingInMM)
+			default:
+				prettyPrinted = fmt.Sprintf("%dmm", r.DistanceMM)
 			}
 			msg += fmt.Sprintf("%s=%5s/%5dmm ", filters[j].Name, prettyPrinted, filters[j].BestGuess())
 		}
 		fmt.Println(msg)
+		return true
+	}
+
+	// readSensorsOrStop reads the sensors for the wall following.  If they've gone quiet, it stops the
+	// bot, rather than let it carry on blind, and returns false.
+	readSensorsOrStop := func(hh hardware.HeadingAbsolute) bool {
+		if readSensors() {
+			return true
+		}
+		hh.SetThrottle(0)
+		return false
 	}
 
 	flushSensors := func() {
@@ -225,6 +249,7 @@ func (s *SLSTMode) runSequence(ctx context.Context) {
 
 	// We use the absolute heading hold mode so we can do things like "turn right 45 degrees".
 	hh := s.hw.StartHeadingHoldMode()
+	s.hw.UseMotionLimits(hardware.LimitsSpeedTest)
 
 	// Let the user know that we're ready, then wait for the "GO" signal.
 	s.hw.PlaySound("/sounds/ready.wav")
@@ -256,7 +281,9 @@ func (s *SLSTMode) runSequence(ctx context.Context) {
 			}
 
 			baseSpeed := float64(s.baseSpeedPct.Get())
-			readSensors()
+			if !readSensorsOrStop(hh) {
+				continue
+			}
 
 			// If we reach a wall in front, break out and do the turn.
 			if frontLeft.IsGood() && frontRight.IsGood() {
@@ -392,11 +419,23 @@ func (s *SLSTMode) runSequence(ctx context.Context) {
 		fmt.Println("SLST: Turn start heading:", startHeading)
 
 		hh.AddHeadingDelta(sign * 45)
-		measuredErr, err := hh.Wait(ctx)
-		if err != nil {
-			fmt.Println("SLST: Error from wait: ", err)
+		result := hh.WaitSettled(ctx, 1, 100*time.Millisecond, 3*time.Second)
+		fmt.Println("SLST: Turn", result)
+		if result.Outcome == headingholder.TimedOut || result.Outcome == headingholder.Oscillating {
+			// Following the walls at the wrong heading would drive us into one; give the turn one more
+			// chance.
+			result = hh.WaitSettled(ctx, 1, 100*time.Millisecond, 3*time.Second)
+			fmt.Println("SLST: Second wait for turn:", result)
+		}
+		switch result.Outcome {
+		case headingholder.Cancelled:
+			return
+		case headingholder.TimedOut, headingholder.Oscillating:
+			fmt.Println("SLST: Turn didn't settle, stopping:", result)
+			hh.SetThrottle(0)
 			return
 		}
+		measuredErr := result.ResidualDegrees
 
 		flushSensors()
 
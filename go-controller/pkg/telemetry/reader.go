@@ -0,0 +1,65 @@
+package telemetry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Entry is a decoded record along with its timestamp.
+type Entry struct {
+	Time   time.Time
+	Record Record
+}
+
+type Reader struct {
+	r         *bufio.Reader
+	StartTime time.Time
+	last      time.Time
+}
+
+// NewReader reads the log header; use Next to read the records.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("failed to read telemetry header: %w", err)
+	}
+	if !bytes.Equal(header, magic) {
+		return nil, errors.New("not a telemetry log")
+	}
+	start, err := binary.ReadVarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read telemetry header: %w", err)
+	}
+	startTime := time.UnixMicro(start)
+	return &Reader{
+		r:         br,
+		StartTime: startTime,
+		last:      startTime,
+	}, nil
+}
+
+// Next returns the next entry or io.EOF at the end of the log.  A log that was cut off part way
+// through a record (for example because the robot lost power) returns io.ErrUnexpectedEOF.
+func (r *Reader) Next() (Entry, error) {
+	kind, err := r.r.ReadByte()
+	if err != nil {
+		return Entry{}, err
+	}
+	d := decoder{r: r.r}
+	delta := d.varint()
+	rec := d.record(Kind(kind))
+	if d.err == io.EOF {
+		d.err = io.ErrUnexpectedEOF
+	}
+	if d.err != nil {
+		return Entry{}, d.err
+	}
+	r.last = r.last.Add(time.Duration(delta) * time.Microsecond)
+	return Entry{Time: r.last, Record: rec}, nil
+}
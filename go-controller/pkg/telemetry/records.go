@@ -0,0 +1,435 @@
+package telemetry
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+type Kind uint8
+
+const (
+	KindMotorSpeeds Kind = iota + 1
+	KindIMU
+	KindRotations
+	KindPower
+	KindJoystick
+	KindMode
+	KindPosition
+	KindTarget
+	KindHeadingHolderStart
+	KindSetHeading
+	KindAddHeadingDelta
+	KindSetThrottleWithAngle
+	KindSetYawAndThrottle
+	KindHeadingHolderStop
+	KindPicoFault
+	KindThermal
+	KindWheelSlip
+	KindSetMotionLimits
+	KindSetHeadingGains
+	KindSetHeadingWithProfile
+)
+
+// Record is one entry in the telemetry log.
+type Record interface {
+	Kind() Kind
+	encode(b []byte) []byte
+}
+
+// MotorSpeeds is a motor command, in the Pico-BLDC's fixed point format.
+type MotorSpeeds struct {
+	FrontLeft, FrontRight, BackLeft, BackRight int16
+}
+
+// IMU is a raw BNO08X report.  We keep our own copy of the struct so that the bno08x package can
+// record its reports without an import cycle.
+type IMU struct {
+	Index                  uint8
+	Yaw, Pitch, Roll       int16
+	XAccel, YAccel, ZAccel int16
+}
+
+// Rotations is a poll of the Pico's accumulated wheel rotations, indexed like picobldc.PerMotorVal.
+type Rotations [4]float64
+
+type Power struct {
+	Bus                int
+	Volts, Amps, Watts float64
+}
+
+type Joystick struct {
+	Type, Number uint8
+	Value        int16
+}
+
+// Mode records a switch of control mode.
+type Mode struct {
+	Name string
+}
+
+// Position is where challengemode believes the bot to be.
+type Position struct {
+	X, Y, Heading float64
+}
+
+// Target is challengemode's decision about where to go next.
+type Target struct {
+	X, Y, Heading float64
+	MoveTime      time.Duration
+	Stop          bool
+}
+
+// HeadingHolderStart marks the start of a heading holder loop, along with the IMU report that the loop
+// started from and the yaw (degrees) that it treats as heading 0.  Relative is true if the loop
+// started in yaw-rate mode; in logs from before the heading holders were merged, it marks the
+// yaw-rate-and-throttle loop.
+type HeadingHolderStart struct {
+	Relative bool
+	Initial  IMU
+	ZeroYaw  float64
+}
+
+// HeadingHolderStop marks the end of a heading holder loop.
+type HeadingHolderStop struct{}
+
+// The heading holders' setpoint calls.  These are stored at full precision so that a replay makes
+// exactly the same calls.
+
+type SetHeading struct {
+	Heading float64
+}
+
+type AddHeadingDelta struct {
+	Delta float64
+}
+
+type SetThrottleWithAngle struct {
+	ThrottleMMPerS, Angle float64
+}
+
+type SetYawAndThrottle struct {
+	YawRate, Throttle, Translation float64
+}
+
+type SetHeadingWithProfile struct {
+	Heading, MaxRate, MaxAccel float64
+}
+
+type SetHeadingGains struct {
+	Kp, Ki, Kd, MaxIntegral, MaxD float64
+}
+
+// SetMotionLimits has the same fields as headingholder.MotionLimits.
+type SetMotionLimits struct {
+	MaxSpeedMMPerS    float64
+	MaxAccelMMPerS2   float64
+	MaxJerkMMPerS3    float64
+	MaxRotationMMPerS float64
+	MaxWheelRPS       float64
+	MaxBatteryAmps    float64
+}
+
+// PicoFault is a problem spotted by the I2C loop's polling of the Pico-BLDC: Fault is a
+// hardware.PicoFaultKind and Status the raw status flags.  Recovered is false if the loop failed to
+// reset the Pico.
+type PicoFault struct {
+	Fault      uint8
+	Status     uint16
+	FaultCount uint16
+	Recovered  bool
+}
+
+// Thermal marks a change in thermal derating: start, end or a significant change of limit.  LimitRPS
+// is 0 when derating ends.
+type Thermal struct {
+	TemperatureC, LimitRPS float64
+}
+
+// WheelSlip marks the start or end of wheel slip: the rotation over the detector's window according
+// to the wheels and the IMU, and the resulting confidence in the odometry.
+type WheelSlip struct {
+	OdometryYaw, IMUYaw, Confidence float64
+}
+
+func (MotorSpeeds) Kind() Kind { return KindMotorSpeeds }
+func (IMU) Kind() Kind         { return KindIMU }
+func (Rotations) Kind() Kind   { return KindRotations }
+func (Power) Kind() Kind       { return KindPower }
+func (Joystick) Kind() Kind    { return KindJoystick }
+func (Mode) Kind() Kind        { return KindMode }
+func (Position) Kind() Kind    { return KindPosition }
+func (Target) Kind() Kind      { return KindTarget }
+
+func (HeadingHolderStart) Kind() Kind    { return KindHeadingHolderStart }
+func (SetHeading) Kind() Kind            { return KindSetHeading }
+func (AddHeadingDelta) Kind() Kind       { return KindAddHeadingDelta }
+func (SetThrottleWithAngle) Kind() Kind  { return KindSetThrottleWithAngle }
+func (SetYawAndThrottle) Kind() Kind     { return KindSetYawAndThrottle }
+func (SetMotionLimits) Kind() Kind       { return KindSetMotionLimits }
+func (SetHeadingGains) Kind() Kind       { return KindSetHeadingGains }
+func (SetHeadingWithProfile) Kind() Kind { return KindSetHeadingWithProfile }
+func (HeadingHolderStop) Kind() Kind     { return KindHeadingHolderStop }
+func (PicoFault) Kind() Kind             { return KindPicoFault }
+func (Thermal) Kind() Kind               { return KindThermal }
+func (WheelSlip) Kind() Kind             { return KindWheelSlip }
+
+// Integers are stored as varints and floats as float32s; plenty of precision for what we record and it
+// keeps the log small enough to leave running.
+
+func appendFloat(b []byte, f float64) []byte {
+	return binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(f)))
+}
+
+func appendFloat64(b []byte, f float64) []byte {
+	return binary.LittleEndian.AppendUint64(b, math.Float64bits(f))
+}
+
+func appendBool(b []byte, v bool) []byte {
+	if v {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
+
+func (r MotorSpeeds) encode(b []byte) []byte {
+	b = binary.AppendVarint(b, int64(r.FrontLeft))
+	b = binary.AppendVarint(b, int64(r.FrontRight))
+	b = binary.AppendVarint(b, int64(r.BackLeft))
+	return binary.AppendVarint(b, int64(r.BackRight))
+}
+
+func (r IMU) encode(b []byte) []byte {
+	b = append(b, r.Index)
+	for _, v := range []int16{r.Yaw, r.Pitch, r.Roll, r.XAccel, r.YAccel, r.ZAccel} {
+		b = binary.AppendVarint(b, int64(v))
+	}
+	return b
+}
+
+func (r Rotations) encode(b []byte) []byte {
+	// Rotations grow without bound over a run so they need more than float32 precision.
+	for _, v := range r {
+		b = appendFloat64(b, v)
+	}
+	return b
+}
+
+func (r Power) encode(b []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(r.Bus))
+	b = appendFloat(b, r.Volts)
+	b = appendFloat(b, r.Amps)
+	return appendFloat(b, r.Watts)
+}
+
+func (r Joystick) encode(b []byte) []byte {
+	b = append(b, r.Type, r.Number)
+	return binary.AppendVarint(b, int64(r.Value))
+}
+
+func (r Mode) encode(b []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(r.Name)))
+	return append(b, r.Name...)
+}
+
+func (r Position) encode(b []byte) []byte {
+	b = appendFloat(b, r.X)
+	b = appendFloat(b, r.Y)
+	return appendFloat(b, r.Heading)
+}
+
+func (r Target) encode(b []byte) []byte {
+	b = appendFloat(b, r.X)
+	b = appendFloat(b, r.Y)
+	b = appendFloat(b, r.Heading)
+	b = binary.AppendVarint(b, r.MoveTime.Microseconds())
+	return appendBool(b, r.Stop)
+}
+
+func (r HeadingHolderStart) encode(b []byte) []byte {
+	b = appendBool(b, r.Relative)
+	b = r.Initial.encode(b)
+	return appendFloat64(b, r.ZeroYaw)
+}
+
+func (r HeadingHolderStop) encode(b []byte) []byte {
+	return b
+}
+
+func (r SetHeading) encode(b []byte) []byte {
+	return appendFloat64(b, r.Heading)
+}
+
+func (r AddHeadingDelta) encode(b []byte) []byte {
+	return appendFloat64(b, r.Delta)
+}
+
+func (r SetThrottleWithAngle) encode(b []byte) []byte {
+	b = appendFloat64(b, r.ThrottleMMPerS)
+	return appendFloat64(b, r.Angle)
+}
+
+func (r SetYawAndThrottle) encode(b []byte) []byte {
+	b = appendFloat64(b, r.YawRate)
+	b = appendFloat64(b, r.Throttle)
+	return appendFloat64(b, r.Translation)
+}
+
+func (r SetMotionLimits) encode(b []byte) []byte {
+	b = appendFloat64(b, r.MaxSpeedMMPerS)
+	b = appendFloat64(b, r.MaxAccelMMPerS2)
+	b = appendFloat64(b, r.MaxJerkMMPerS3)
+	b = appendFloat64(b, r.MaxRotationMMPerS)
+	b = appendFloat64(b, r.MaxWheelRPS)
+	return appendFloat64(b, r.MaxBatteryAmps)
+}
+
+func (r SetHeadingWithProfile) encode(b []byte) []byte {
+	b = appendFloat64(b, r.Heading)
+	b = appendFloat64(b, r.MaxRate)
+	return appendFloat64(b, r.MaxAccel)
+}
+
+func (r SetHeadingGains) encode(b []byte) []byte {
+	b = appendFloat64(b, r.Kp)
+	b = appendFloat64(b, r.Ki)
+	b = appendFloat64(b, r.Kd)
+	b = appendFloat64(b, r.MaxIntegral)
+	return appendFloat64(b, r.MaxD)
+}
+
+func (r PicoFault) encode(b []byte) []byte {
+	b = append(b, r.Fault)
+	b = binary.AppendUvarint(b, uint64(r.Status))
+	b = binary.AppendUvarint(b, uint64(r.FaultCount))
+	return appendBool(b, r.Recovered)
+}
+
+func (r Thermal) encode(b []byte) []byte {
+	b = appendFloat(b, r.TemperatureC)
+	return appendFloat(b, r.LimitRPS)
+}
+
+func (r WheelSlip) encode(b []byte) []byte {
+	b = appendFloat(b, r.OdometryYaw)
+	b = appendFloat(b, r.IMUYaw)
+	return appendFloat(b, r.Confidence)
+}
+
+const maxModeNameLen = 256
+
+// decoder reads record payloads; the first error sticks so that callers only need to check at the end.
+type decoder struct {
+	r   io.ByteReader
+	err error
+}
+
+func (d *decoder) byte() uint8 {
+	if d.err != nil {
+		return 0
+	}
+	var b byte
+	b, d.err = d.r.ReadByte()
+	return b
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	var v int64
+	v, d.err = binary.ReadVarint(d.r)
+	return v
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	var v uint64
+	v, d.err = binary.ReadUvarint(d.r)
+	return v
+}
+
+func (d *decoder) uint32() uint32 {
+	var v uint32
+	for i := 0; i < 4; i++ {
+		v |= uint32(d.byte()) << (8 * i)
+	}
+	return v
+}
+
+func (d *decoder) float() float64 {
+	return float64(math.Float32frombits(d.uint32()))
+}
+
+func (d *decoder) float64() float64 {
+	lo, hi := d.uint32(), d.uint32()
+	return math.Float64frombits(uint64(hi)<<32 | uint64(lo))
+}
+
+func (d *decoder) int16() int16 {
+	return int16(d.varint())
+}
+
+func (d *decoder) imu() IMU {
+	return IMU{d.byte(), d.int16(), d.int16(), d.int16(), d.int16(), d.int16(), d.int16()}
+}
+
+func (d *decoder) record(kind Kind) Record {
+	switch kind {
+	case KindMotorSpeeds:
+		return MotorSpeeds{d.int16(), d.int16(), d.int16(), d.int16()}
+	case KindIMU:
+		return d.imu()
+	case KindRotations:
+		return Rotations{d.float64(), d.float64(), d.float64(), d.float64()}
+	case KindPower:
+		return Power{int(d.uvarint()), d.float(), d.float(), d.float()}
+	case KindJoystick:
+		return Joystick{d.byte(), d.byte(), d.int16()}
+	case KindMode:
+		n := d.uvarint()
+		if n > maxModeNameLen {
+			d.err = fmt.Errorf("mode name too long (%d bytes)", n)
+			return nil
+		}
+		name := make([]byte, n)
+		for i := range name {
+			name[i] = d.byte()
+		}
+		return Mode{string(name)}
+	case KindPosition:
+		return Position{d.float(), d.float(), d.float()}
+	case KindTarget:
+		return Target{d.float(), d.float(), d.float(), time.Duration(d.varint()) * time.Microsecond, d.byte() != 0}
+	case KindHeadingHolderStart:
+		return HeadingHolderStart{d.byte() != 0, d.imu(), d.float64()}
+	case KindHeadingHolderStop:
+		return HeadingHolderStop{}
+	case KindSetHeading:
+		return SetHeading{d.float64()}
+	case KindAddHeadingDelta:
+		return AddHeadingDelta{d.float64()}
+	case KindSetThrottleWithAngle:
+		return SetThrottleWithAngle{d.float64(), d.float64()}
+	case KindSetYawAndThrottle:
+		return SetYawAndThrottle{d.float64(), d.float64(), d.float64()}
+	case KindSetMotionLimits:
+		return SetMotionLimits{d.float64(), d.float64(), d.float64(), d.float64(), d.float64(), d.float64()}
+	case KindSetHeadingWithProfile:
+		return SetHeadingWithProfile{d.float64(), d.float64(), d.float64()}
+	case KindSetHeadingGains:
+		return SetHeadingGains{d.float64(), d.float64(), d.float64(), d.float64(), d.float64()}
+	case KindThermal:
+		return Thermal{d.float(), d.float()}
+	case KindWheelSlip:
+		return WheelSlip{d.float(), d.float(), d.float()}
+	case KindPicoFault:
+		return PicoFault{d.byte(), uint16(d.uvarint()), uint16(d.uvarint()), d.byte() != 0}
+	}
+	d.err = fmt.Errorf("unknown record kind %d", kind)
+	return nil
+}
@@ -0,0 +1,227 @@
+// Package telemetry is a flight recorder for runs.  The Record* functions queue timestamped records
+// which a background goroutine writes to a compact binary log, one file per controller run.  Recording
+// never blocks: if the writer falls behind, records are dropped and counted.
+package telemetry
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultDir = "/tmp/telemetry"
+
+	queueLen      = 4096
+	flushInterval = time.Second
+)
+
+// magic starts every log file, followed by the start time as a varint of Unix microseconds.  Each
+// record is then a kind byte, its time as a varint delta from the previous record (both measured in
+// whole microseconds since the start, so that rounding errors don't accumulate) and the record's
+// payload.
+var magic = []byte("TBTLM\x01")
+
+type entry struct {
+	time   time.Time
+	record Record
+}
+
+var (
+	lock    sync.Mutex
+	queue   chan entry
+	done    chan struct{}
+	dropped atomic.Uint64
+)
+
+// Start opens a new log file in $TELEMETRY_DIR (default /tmp/telemetry) and starts the background
+// writer.  Until Start is called, records are discarded.
+func Start(ctx context.Context) error {
+	dir := os.Getenv("TELEMETRY_DIR")
+	if dir == "" {
+		dir = defaultDir
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	now := time.UnixMicro(time.Now().UnixMicro())
+	path := filepath.Join(dir, now.Format("run-20060102-150405.tlm"))
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	header := binary.AppendVarint(append([]byte{}, magic...), now.UnixMicro())
+	if _, err := w.Write(header); err != nil {
+		_ = f.Close()
+		return err
+	}
+	fmt.Println("Telemetry: recording to", path)
+
+	lock.Lock()
+	defer lock.Unlock()
+	queue = make(chan entry, queueLen)
+	done = make(chan struct{})
+	go writeLoop(ctx, f, w, now, queue, done)
+	return nil
+}
+
+// Stop flushes any queued records and closes the log.
+func Stop() {
+	lock.Lock()
+	q, d := queue, done
+	queue = nil
+	lock.Unlock()
+
+	if q == nil {
+		return
+	}
+	close(q)
+	<-d
+	if n := dropped.Load(); n > 0 {
+		fmt.Println("Telemetry: dropped", n, "records")
+	}
+}
+
+func writeLoop(ctx context.Context, f *os.File, w *bufio.Writer, start time.Time, q chan entry, done chan struct{}) {
+	defer close(done)
+	defer func() {
+		_ = w.Flush()
+		_ = f.Close()
+	}()
+
+	flushTicker := time.NewTicker(flushInterval)
+	defer flushTicker.Stop()
+
+	var buf []byte
+	var lastMicros int64
+	for {
+		select {
+		case e, ok := <-q:
+			if !ok {
+				return
+			}
+			buf = append(buf[:0], byte(e.record.Kind()))
+			micros := e.time.Sub(start).Microseconds()
+			buf = binary.AppendVarint(buf, micros-lastMicros)
+			buf = e.record.encode(buf)
+			lastMicros = micros
+			if _, err := w.Write(buf); err != nil {
+				fmt.Println("Telemetry: failed to write log, giving up", err)
+				return
+			}
+		case <-flushTicker.C:
+			_ = w.Flush()
+		case <-ctx.Done():
+			// Keep draining until Stop closes the queue so that the end of the run gets recorded.
+			ctx = context.Background()
+		}
+	}
+}
+
+// RecordAt queues a record with the given timestamp.
+func RecordAt(t time.Time, r Record) {
+	lock.Lock()
+	defer lock.Unlock()
+	if queue == nil {
+		return
+	}
+	select {
+	case queue <- entry{t, r}:
+	default:
+		dropped.Add(1)
+	}
+}
+
+func record(r Record) {
+	RecordAt(time.Now(), r)
+}
+
+func RecordMotorSpeeds(frontLeft, frontRight, backLeft, backRight int16) {
+	record(MotorSpeeds{frontLeft, frontRight, backLeft, backRight})
+}
+
+// RecordIMU records a BNO08X report, timestamped with the time that it was received.
+func RecordIMU(t time.Time, r IMU) {
+	RecordAt(t, r)
+}
+
+func RecordRotations(rotations [4]float64) {
+	record(Rotations(rotations))
+}
+
+func RecordPower(bus int, volts, amps, watts float64) {
+	record(Power{bus, volts, amps, watts})
+}
+
+func RecordJoystick(t time.Time, eventType, number uint8, value int16) {
+	RecordAt(t, Joystick{eventType, number, value})
+}
+
+func RecordMode(name string) {
+	record(Mode{name})
+}
+
+func RecordPosition(x, y, heading float64) {
+	record(Position{x, y, heading})
+}
+
+func RecordTarget(x, y, heading float64, moveTime time.Duration, stop bool) {
+	record(Target{x, y, heading, moveTime, stop})
+}
+
+// RecordHeadingHolderStart is timestamped with the time of the initial IMU report.
+func RecordHeadingHolderStart(t time.Time, relative bool, initial IMU, zeroYaw float64) {
+	RecordAt(t, HeadingHolderStart{relative, initial, zeroYaw})
+}
+
+func RecordHeadingHolderStop() {
+	record(HeadingHolderStop{})
+}
+
+func RecordSetHeading(heading float64) {
+	record(SetHeading{heading})
+}
+
+func RecordSetHeadingWithProfile(heading, maxRate, maxAccel float64) {
+	record(SetHeadingWithProfile{heading, maxRate, maxAccel})
+}
+
+func RecordAddHeadingDelta(delta float64) {
+	record(AddHeadingDelta{delta})
+}
+
+func RecordSetThrottleWithAngle(throttleMMPerS, angle float64) {
+	record(SetThrottleWithAngle{throttleMMPerS, angle})
+}
+
+func RecordSetYawAndThrottle(yawRate, throttle, translation float64) {
+	record(SetYawAndThrottle{yawRate, throttle, translation})
+}
+
+func RecordSetMotionLimits(limits SetMotionLimits) {
+	record(limits)
+}
+
+func RecordSetHeadingGains(kp, ki, kd, maxIntegral, maxD float64) {
+	record(SetHeadingGains{kp, ki, kd, maxIntegral, maxD})
+}
+
+func RecordThermal(temperatureC, limitRPS float64) {
+	record(Thermal{temperatureC, limitRPS})
+}
+
+func RecordWheelSlip(odometryYaw, imuYaw, confidence float64) {
+	record(WheelSlip{odometryYaw, imuYaw, confidence})
+}
+
+func RecordPicoFault(fault uint8, status, faultCount uint16, recovered bool) {
+	record(PicoFault{fault, status, faultCount, recovered})
+}
@@ -0,0 +1,85 @@
+package telemetry
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TELEMETRY_DIR", dir)
+
+	if err := Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	imuTime := time.Now().Add(-5 * time.Millisecond) // Out of order w.r.t. the previous record.
+	expected := []Record{
+		Mode{"RC MODE"},
+		MotorSpeeds{100, -100, 1024, -32768},
+		IMU{7, 1, 9000, -17999, 3, -4, 5},
+		Rotations{1.5, -2.25, 1000.125, 0},
+		Power{1, 16.5, 2.25, 37.125},
+		Joystick{1, 9, 1},
+		Position{750, -250, 90},
+		Target{1000, 500, -45, 1500 * time.Millisecond, true},
+		HeadingHolderStart{true, IMU{1, 2, 3, 4, 5, 6, 7}, -179.5},
+		HeadingHolderStop{},
+		SetHeading{-123.456789},
+		AddHeadingDelta{0.1},
+		SetThrottleWithAngle{300.3, 12.3456},
+		SetYawAndThrottle{0.5, -0.25, 1e-9},
+		SetMotionLimits{1000, 2000, 0, 400, 5.5, 12.25},
+		SetHeadingGains{6, 0.8, 0.123456789, 20, 100},
+		SetHeadingWithProfile{-135.5, 180, 360},
+		PicoFault{2, 0x8005, 300, true},
+		Thermal{72.5, 2.75},
+		WheelSlip{12.5, -3.25, 0.5},
+	}
+	for _, r := range expected {
+		if r.Kind() == KindIMU {
+			RecordIMU(imuTime, r.(IMU))
+			continue
+		}
+		RecordAt(time.Now(), r)
+	}
+	Stop()
+	// Recording after Stop is a no-op.
+	RecordMode("ignored")
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.tlm"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Expected one log file, got %v (%v)", files, err)
+	}
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	r, err := NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []Record
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Record.Kind() == KindIMU && e.Time.Sub(imuTime).Abs() > time.Microsecond {
+			t.Errorf("IMU record has wrong timestamp %v, expected %v", e.Time, imuTime)
+		}
+		actual = append(actual, e.Record)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("Records didn't round trip:\n%#v\n%#v", actual, expected)
+	}
+}
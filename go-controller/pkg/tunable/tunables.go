@@ -8,11 +8,17 @@ import (
 type Tunable struct {
 	Name  string
 	Value int64
+
+	// OnChange, if set, is called with the new value after each Add.
+	OnChange func(newValue int)
 }
 
 func (t *Tunable) Add(delta int) {
 	newV := atomic.AddInt64(&t.Value, int64(delta))
 	fmt.Println("Tunable", t.Name, "=", newV)
+	if t.OnChange != nil {
+		t.OnChange(int(newV))
+	}
 }
 
 func (t *Tunable) Get() int {